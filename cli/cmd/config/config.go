@@ -0,0 +1,120 @@
+// Package config provides the commands for reading and writing the CLI config file.
+package config
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	otsconfig "github.com/brentdalling/ots-cli/internal/config"
+)
+
+// ConfigCmd is the cobra command grouping the config subcommands.
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change CLI settings",
+	Long: "Show and change CLI settings.\n\n" +
+		"Settings are layered: built-in defaults, then the config file, then OTS_* environment variables, then flags.\n" +
+		"The config file is config.toml in $XDG_CONFIG_HOME/ots (~/.config/ots on Linux), or the path in OTS_CONFIG.",
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a setting",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := otsconfig.Load()
+		if err != nil {
+			return err
+		}
+		value, _, err := cfg.Get(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+	},
+}
+
+var setCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Write a setting to the config file",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := otsconfig.GetConfigPath()
+		if path == "" {
+			return fmt.Errorf("cannot locate the config directory; set OTS_CONFIG")
+		}
+		if err := otsconfig.Set(path, args[0], args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Set %s = %s in %s\n", args[0], args[1], path)
+		return nil
+	},
+}
+
+var unsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a setting from the config file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := otsconfig.GetConfigPath()
+		if path == "" {
+			return fmt.Errorf("cannot locate the config directory; set OTS_CONFIG")
+		}
+		removed, err := otsconfig.Unset(path, args[0])
+		if err != nil {
+			return err
+		}
+		if removed {
+			fmt.Printf("✓ Removed %s from %s\n", args[0], path)
+		} else {
+			fmt.Printf("%s is not set in %s\n", args[0], path)
+		}
+		return nil
+	},
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all settings with their effective values and sources",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := otsconfig.Load()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tVALUE\tSOURCE\tENV")
+		for _, name := range otsconfig.Keys() {
+			value, source, err := cfg.Get(name)
+			if err != nil {
+				return err
+			}
+			_, env := otsconfig.Help(name)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, value, source, env)
+		}
+		return w.Flush()
+	},
+}
+
+var pathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the config file path",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := otsconfig.GetConfigPath()
+		if path == "" {
+			return fmt.Errorf("cannot locate the config directory; set OTS_CONFIG")
+		}
+		fmt.Println(path)
+		return nil
+	},
+}
+
+func init() {
+	ConfigCmd.AddCommand(getCmd, setCmd, unsetCmd, listCmd, pathCmd)
+}
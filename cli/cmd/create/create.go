@@ -5,23 +5,60 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/atotto/clipboard"
 	"github.com/spf13/cobra"
 
+	"github.com/brentdalling/ots-cli/cmd/otsclient"
 	"github.com/brentdalling/ots-cli/internal/api"
+	"github.com/brentdalling/ots-cli/internal/bundle"
 	"github.com/brentdalling/ots-cli/internal/config"
 	"github.com/brentdalling/ots-cli/internal/crypto"
+	"github.com/brentdalling/ots-cli/internal/history"
+	"github.com/brentdalling/ots-cli/internal/output"
+	"github.com/brentdalling/ots-cli/internal/passgen"
+	"github.com/brentdalling/ots-cli/internal/prompt"
+	"github.com/brentdalling/ots-cli/pkg/ots"
+)
+
+// Forms of the key printed by --split.
+const (
+	splitHex   = "hex"
+	splitWords = "words"
 )
 
 var (
-	password      string
-	burnAfterRead bool
-	expiresIn     string
-	filePath      string
-	noClipboard   bool
-	serverURL     string
-	secretText    string
+	password       string
+	generate       string
+	accessPassword string
+	burnAfterRead  bool
+	maxReads       int
+	expiresIn      string
+	filePath       string
+	noClipboard    bool
+	serverURL      string
+	secretText     string
+	legacyFormat   bool
+	keyInFragment  bool
+	split          string
+	record         bool
+	label          string
+
+	// generated is set when the password was generated rather than supplied, so it must be shown
+	generated bool
+
+	kdfName           string
+	argon2Time        uint32
+	argon2Memory      uint32
+	argon2Parallelism uint8
+	scryptN           int
+	scryptR           int
+	scryptP           int
+	pbkdf2Iterations  int
 )
 
 // CreateCmd is the cobra command for creating secrets.
@@ -29,127 +66,443 @@ var CreateCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create a new one-time secret",
 	Long:  "Create a new one-time secret with optional password protection and expiration",
+	Args:  createArgs,
 	RunE:  runCreate,
 }
 
 func init() {
 	CreateCmd.Flags().StringVarP(&password, "password", "p", "", "Password to protect the secret")
+	CreateCmd.Flags().StringVar(&generate, "generate-password", "", "Protect the secret with a generated password of this length, or a passphrase with =diceware")
+	CreateCmd.Flags().Lookup("generate-password").NoOptDefVal = strconv.Itoa(passgen.DefaultLength)
+	CreateCmd.Flags().StringVar(&accessPassword, "access-password", "", "Password the server requires before releasing the secret")
 	CreateCmd.Flags().BoolVarP(&burnAfterRead, "burn-after-read", "b", false, "Destroy secret after first read")
+	CreateCmd.Flags().IntVar(&maxReads, "max-reads", 1, fmt.Sprintf("Number of times the link can be read, 1-%d (e.g. one per recipient)", api.MaxReadsLimit))
 	CreateCmd.Flags().StringVarP(&expiresIn, "expires-in", "e", "7d", "Expiration time (e.g., 1h, 24h, 7d)")
-	CreateCmd.Flags().StringVarP(&filePath, "file", "f", "", "Read secret from file instead of stdin")
+	CreateCmd.Flags().StringVarP(&filePath, "file", "f", "", "Share a file or directory (name and mode are preserved)")
 	CreateCmd.Flags().StringVarP(&secretText, "text", "t", "", "Secret text (alternative to stdin or file)")
 	CreateCmd.Flags().BoolVarP(&noClipboard, "no-clipboard", "n", false, "Don't copy link to clipboard")
 	CreateCmd.Flags().StringVarP(&serverURL, "server", "s", "", "Override server URL")
+	CreateCmd.Flags().BoolVar(&record, "history", false, "Record this secret in the local history (default from the history setting)")
+	CreateCmd.Flags().StringVar(&label, "label", "", "Label for the history entry (implies --history)")
+	CreateCmd.Flags().BoolVar(&legacyFormat, "legacy", false, "Use the unauthenticated AES-CBC format readable by the web interface")
+	CreateCmd.Flags().BoolVar(&keyInFragment, "key-in-fragment", false, "Put the key in the link's #fragment, which browsers never send to the server")
+	CreateCmd.Flags().StringVar(&split, "split", "", "Print the link without its key and the key separately, as hex or with =words as a word code")
+	CreateCmd.Flags().Lookup("split").NoOptDefVal = splitHex
+	CreateCmd.Flags().StringVar(&kdfName, "kdf", crypto.KDFArgon2id, "Password key derivation function (argon2id, scrypt, pbkdf2)")
+	CreateCmd.Flags().Uint32Var(&argon2Time, "argon2-time", crypto.Argon2Time, "Argon2id time cost (passes)")
+	CreateCmd.Flags().Uint32Var(&argon2Memory, "argon2-memory", crypto.Argon2Memory, "Argon2id memory cost in KiB")
+	CreateCmd.Flags().Uint8Var(&argon2Parallelism, "argon2-parallelism", crypto.Argon2Parallelism, "Argon2id parallelism (lanes)")
+	CreateCmd.Flags().IntVar(&scryptN, "scrypt-n", crypto.ScryptN, "scrypt CPU/memory cost N (power of two)")
+	CreateCmd.Flags().IntVar(&scryptR, "scrypt-r", crypto.ScryptR, "scrypt block size r")
+	CreateCmd.Flags().IntVar(&scryptP, "scrypt-p", crypto.ScryptP, "scrypt parallelization p")
+	CreateCmd.Flags().IntVar(&pbkdf2Iterations, "pbkdf2-iterations", crypto.PBKDF2SaltedIterations, "Salted PBKDF2-SHA256 iterations (not used with --legacy)")
+}
+
+// createArgs rejects positional arguments. --generate-password and --split take a value only
+// after =, so in "--split words" the word is an argument and would otherwise be dropped.
+func createArgs(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	for _, name := range []string{"generate-password", "split"} {
+		if f := cmd.Flags().Lookup(name); f.Changed && f.Value.String() == f.NoOptDefVal {
+			return &output.UsageError{Err: fmt.Errorf("unexpected argument %q; --%s takes its value after =, e.g. --%s=%s", args[0], name, name, args[0])}
+		}
+	}
+	return &output.UsageError{Err: fmt.Errorf("unexpected argument %q; pass the secret with --text, --file or stdin", args[0])}
 }
 
 // runCreate handles the create command execution.
 // It reads the secret from stdin, file, or text flag, encrypts it, and sends it to the server.
+// With none of those and a terminal on stdin, it asks for the secret and its settings instead.
+// Files and directories are packed into a bundle so binary content and the original name survive.
 func runCreate(cmd *cobra.Command, args []string) error {
-	cfg := config.LoadConfig()
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
 	if serverURL != "" {
 		cfg.ServerURL = serverURL
 	}
+	if !cmd.Flags().Changed("expires-in") {
+		expiresIn = cfg.ExpiresIn
+	}
+	if !cmd.Flags().Changed("burn-after-read") {
+		burnAfterRead = cfg.BurnAfterRead
+	}
+	if !cmd.Flags().Changed("no-clipboard") {
+		noClipboard = !cfg.Clipboard
+	}
+	if accessPassword != "" && legacyFormat {
+		return &output.UsageError{Err: fmt.Errorf("--access-password cannot be used with --legacy (the web interface cannot send an access password)")}
+	}
+	if err := checkSplit(cmd); err != nil {
+		return err
+	}
+	// Check the flags before asking for or reading the secret, which a rejection would waste
+	if err := checkReadLimit(cmd); err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("generate-password") {
+		if cmd.Flags().Changed("password") {
+			return &output.UsageError{Err: fmt.Errorf("--generate-password cannot be combined with --password")}
+		}
+		var entropy float64
+		password, entropy, err = generatePassword(generate)
+		if err != nil {
+			return &output.UsageError{Err: fmt.Errorf("--generate-password: %w", err)}
+		}
+		generated = true
+		if output.Current() == output.Text {
+			fmt.Fprintf(os.Stderr, "Generated a password with about %.0f bits of entropy.\n", entropy)
+		}
+	} else if cmd.Flags().Changed("password") {
+		warnIfWeak(password)
+	}
+
+	var secret []byte
+	if secretText == "" && filePath == "" && prompt.IsTerminal() {
+		secret, err = interactive(cmd, cfg)
+		if err != nil {
+			return err
+		}
+	} else {
+		secret, err = readSecret()
+		if err != nil {
+			return fmt.Errorf("read secret: %w", err)
+		}
+	}
+	if len(secret) == 0 {
+		return fmt.Errorf("secret cannot be empty")
+	}
 
-	secret, err := readSecret()
+	kdf, err := kdfFromFlags(cmd, cfg.KDF)
 	if err != nil {
-		return fmt.Errorf("read secret: %w", err)
+		return err
 	}
 
-	if secret == "" {
-		return fmt.Errorf("secret cannot be empty")
+	opts := []ots.Option{
+		ots.WithExpiresIn(expiresIn),
+		ots.WithPassword(password),
+		ots.WithAccessPassword(accessPassword),
+		ots.WithKDF(kdf),
+		ots.WithProgress(func(index, total int) {
+			fmt.Fprintf(os.Stderr, "Uploading part %d/%d...\n", index+1, total)
+		}),
+	}
+	if burnAfterRead {
+		opts = append(opts, ots.WithBurnAfterRead())
+	} else if cmd.Flags().Changed("max-reads") {
+		opts = append(opts, ots.WithMaxReads(maxReads))
+	}
+	if legacyFormat {
+		opts = append(opts, ots.WithLegacyFormat())
+	}
+	if keyInFragment {
+		opts = append(opts, ots.WithKeyInFragment())
+	}
+	if split != "" {
+		opts = append(opts, ots.WithSplitKey())
 	}
 
-	encrypted, err := crypto.EncryptSecret(secret, password)
+	client, err := otsclient.New(cfg, cfg.ServerURL)
 	if err != nil {
-		return fmt.Errorf("encrypt secret: %w", err)
+		return err
+	}
+	shared, err := client.Share(cmd.Context(), secret, opts...)
+	if err != nil {
+		return err
 	}
 
-	req := &api.CreateSecretRequest{
-		Ciphertext: encrypted.Ciphertext,
-		IV:         encrypted.IV,
-		Salt:       encrypted.Salt,
-		KDF:        "pbkdf2",
-		KDFParams: map[string]interface{}{
-			"iterations":          crypto.PBKDF2Iterations,
-			"isPasswordProtected": password != "",
-		},
+	if !cmd.Flags().Changed("history") {
+		record = cfg.History
+	}
+	if record || label != "" {
+		recordHistory(cfg.ServerURL, shared)
 	}
 
-	if burnAfterRead {
-		req.BurnAfterRead = &burnAfterRead
+	key, err := splitKey(shared)
+	if err != nil {
+		return err
 	}
+	return outputResult(shared, key, password, generated, accessPassword, noClipboard)
+}
 
-	if expiresIn != "" {
-		req.ExpiresIn = expiresIn
+// checkSplit validates --split, whose link carries no key.
+func checkSplit(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("split") {
+		return nil
+	}
+	if split != splitHex && split != splitWords {
+		return &output.UsageError{Err: fmt.Errorf("--split: expected %s or %s, got %q", splitHex, splitWords, split)}
+	}
+	if keyInFragment {
+		return &output.UsageError{Err: fmt.Errorf("--key-in-fragment cannot be combined with --split, whose link has no key")}
 	}
+	return nil
+}
 
-	client := api.NewClient(cfg.ServerURL)
-	resp, err := client.CreateSecret(req)
+// splitKey returns the key to send apart from the link with --split, in the chosen form, or "" without it.
+func splitKey(shared *ots.Shared) (string, error) {
+	switch split {
+	case "":
+		return "", nil
+	case splitWords:
+		return ots.KeyWords(shared.Key)
+	}
+	return shared.Key, nil
+}
+
+// generatePassword creates the password requested by --generate-password: a passphrase for "diceware",
+// otherwise a password of that many characters from every class. It also returns the entropy in bits.
+func generatePassword(spec string) (string, float64, error) {
+	if strings.EqualFold(spec, "diceware") {
+		phrase, err := passgen.Passphrase(passgen.DefaultWords, passgen.DefaultSeparator)
+		return phrase, passgen.PassphraseEntropy(passgen.DefaultWords), err
+	}
+	length, err := strconv.Atoi(spec)
 	if err != nil {
-		return fmt.Errorf("create secret: %w", err)
+		return "", 0, fmt.Errorf("expected a length or diceware, got %q", spec)
+	}
+	generated, err := passgen.Password(length, passgen.AllClasses)
+	return generated, passgen.PasswordEntropy(length, passgen.AllClasses), err
+}
+
+// warnIfWeak warns when a password chosen by the user is easy to guess.
+func warnIfWeak(password string) {
+	if password != "" && passgen.Weak(password) {
+		fmt.Fprintf(os.Stderr, "Warning: the password is weak (about %.0f bits of entropy, %d recommended). Consider --generate-password.\n",
+			passgen.Estimate(password), passgen.MinEntropy)
 	}
+}
 
-	outputResult(cfg.ServerURL, resp.ID, encrypted.Key, password, noClipboard)
+// checkReadLimit validates --max-reads against the server's bounds and against burn-after-read.
+// An explicit --max-reads overrides a burn-after-read setting from the config file, but not the flag.
+func checkReadLimit(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("max-reads") {
+		return nil
+	}
+	if maxReads < 1 || maxReads > api.MaxReadsLimit {
+		return &output.UsageError{Err: fmt.Errorf("--max-reads must be between 1 and %d", api.MaxReadsLimit)}
+	}
+	if burnAfterRead && maxReads > 1 {
+		if cmd.Flags().Changed("burn-after-read") {
+			return &output.UsageError{Err: fmt.Errorf("--burn-after-read allows a single read and cannot be combined with --max-reads %d", maxReads)}
+		}
+		burnAfterRead = false
+	}
 	return nil
 }
 
-// outputResult prints the creation result and optionally copies the link to clipboard.
+// recordHistory adds the created secret to the local ledger, without its key.
+// A failure is only reported: the secret exists and its link must still be printed.
+func recordHistory(serverURL string, shared *ots.Shared) {
+	entry := history.Entry{
+		ID:        shared.ID,
+		Server:    serverURL,
+		Label:     label,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		ExpiresAt: shared.ExpiresAt,
+		MaxReads:  shared.RemainingReads,
+		Parts:     shared.Parts,
+	}
+
+	store, err := history.Default()
+	if err == nil {
+		err = store.Add(entry)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not record secret in history: %v\n", err)
+	}
+}
+
+// kdfFromFlags builds the password KDF from --kdf and its cost flags, falling back to the configured KDF.
+// With --legacy and no explicit --kdf, the web-compatible PBKDF2 scheme is used.
+func kdfFromFlags(cmd *cobra.Command, configured string) (crypto.KDFParams, error) {
+	name := kdfName
+	if !cmd.Flags().Changed("kdf") {
+		name = configured
+		if legacyFormat {
+			name = crypto.KDFPBKDF2
+		}
+	}
+
+	kdf, err := crypto.DefaultKDFParams(name)
+	if err != nil {
+		return kdf, fmt.Errorf("invalid --kdf: %w", err)
+	}
+
+	switch kdf.Name {
+	case crypto.KDFArgon2id:
+		kdf.Time = argon2Time
+		kdf.Memory = argon2Memory
+		kdf.Parallelism = argon2Parallelism
+	case crypto.KDFScrypt:
+		kdf.N = scryptN
+		kdf.R = scryptR
+		kdf.P = scryptP
+	case crypto.KDFPBKDF2:
+		if legacyFormat {
+			if cmd.Flags().Changed("pbkdf2-iterations") {
+				return kdf, &output.UsageError{Err: fmt.Errorf("--pbkdf2-iterations cannot be used with --legacy (the web format uses a fixed %d)", crypto.PBKDF2Iterations)}
+			}
+			break
+		}
+		kdf.Iterations = pbkdf2Iterations
+	}
+
+	if err := kdf.Validate(); err != nil {
+		return kdf, fmt.Errorf("invalid --kdf parameters: %w", err)
+	}
+	return kdf, nil
+}
+
+// createResult is the JSON form of a created secret.
+type createResult struct {
+	ID                      string     `json:"id"`
+	Link                    string     `json:"link"`
+	ExpiresAt               *time.Time `json:"expiresAt"`
+	RemainingReads          int        `json:"remainingReads"`
+	PasswordProtected       bool       `json:"passwordProtected"`
+	Key                     string     `json:"key,omitempty"`
+	GeneratedPassword       string     `json:"generatedPassword,omitempty"`
+	AccessPasswordProtected bool       `json:"accessPasswordProtected"`
+	Parts                   int        `json:"parts,omitempty"`
+	CopiedToClipboard       bool       `json:"copiedToClipboard"`
+}
+
+// outputResult prints the creation result in the selected format and optionally copies the link to clipboard.
+// A generated password is included even in JSON, and goes to stderr with --output raw, as it is known nowhere else.
 // The encryption key is embedded in the URL query parameter - it never leaves the client.
-func outputResult(serverURL, id, key, password string, noClipboard bool) {
-	// Always construct URL from ID to ensure it's present
-	// Encryption key never sent to server, only exists in URL query param
-	link := fmt.Sprintf("%s/s/%s?key=%s", serverURL, id, key)
+// With --split, key is printed apart from the link in the same way; it is never copied to the clipboard.
+func outputResult(shared *ots.Shared, key, password string, generated bool, accessPassword string, noClipboard bool) error {
+	link := shared.Link
+
+	copied := false
+	if !noClipboard {
+		copied = clipboard.WriteAll(link) == nil
+	}
+
+	var generatedPassword string
+	if generated {
+		generatedPassword = password
+	}
+
+	switch output.Current() {
+	case output.JSON:
+		return output.PrintJSON(createResult{
+			ID:                      shared.ID,
+			Link:                    link,
+			Key:                     key,
+			ExpiresAt:               shared.ExpiresAt,
+			RemainingReads:          shared.RemainingReads,
+			PasswordProtected:       password != "",
+			GeneratedPassword:       generatedPassword,
+			AccessPasswordProtected: accessPassword != "",
+			Parts:                   len(shared.Parts),
+			CopiedToClipboard:       copied,
+		})
+	case output.Raw:
+		if key != "" {
+			fmt.Fprintf(os.Stderr, "Key: %s\n", key)
+		}
+		if generated {
+			fmt.Fprintf(os.Stderr, "Password: %s\n", password)
+		}
+		fmt.Println(link)
+		return nil
+	}
 
 	fmt.Println("Secret created successfully!")
 	fmt.Println()
 	fmt.Println("Link:")
 	fmt.Println(link)
 
+	if key != "" {
+		fmt.Println()
+		fmt.Println("Key (send it through a different channel than the link):")
+		fmt.Println(key)
+	}
+
+	fmt.Println()
+	fmt.Println("Reads:")
+	if shared.RemainingReads == 1 {
+		fmt.Println("1 (destroyed after the first read)")
+	} else {
+		fmt.Printf("%d (the link can be opened %d times, e.g. once per recipient)\n", shared.RemainingReads, shared.RemainingReads)
+	}
+
 	if password != "" {
 		fmt.Println()
 		fmt.Println("Password:")
 		fmt.Println(password)
 	}
 
-	if !noClipboard {
-		if err := clipboard.WriteAll(link); err == nil {
-			fmt.Println()
-			fmt.Println("✓ Link copied to clipboard")
-		}
+	// The access password is a second factor: keep it out of whatever the link is copied or logged from
+	if accessPassword != "" {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Access password (send it separately from the link):")
+		fmt.Fprintln(os.Stderr, accessPassword)
 	}
+
+	if copied {
+		fmt.Println()
+		fmt.Println("✓ Link copied to clipboard")
+	}
+	return nil
 }
 
 // readSecret reads the secret from one of three sources (in priority order):
 // 1. --text flag
-// 2. --file flag
+// 2. --file flag (packed as a bundle, or raw contents with --legacy)
 // 3. stdin (pipe)
-func readSecret() (string, error) {
+func readSecret() ([]byte, error) {
 	if secretText != "" {
-		return secretText, nil
+		return []byte(secretText), nil
 	}
 
 	if filePath != "" {
-		data, err := os.ReadFile(filePath)
-		if err != nil {
-			return "", fmt.Errorf("read file: %w", err)
-		}
-		return string(data), nil
+		return readFile(filePath)
 	}
 
 	return readStdin()
 }
 
-// readStdin reads secret from standard input.
-// Returns an error if stdin is a terminal (not a pipe).
-func readStdin() (string, error) {
-	stat, _ := os.Stdin.Stat()
-	if (stat.Mode() & os.ModeCharDevice) != 0 {
-		return "", fmt.Errorf("no input provided. Use --text, --file, or pipe input")
+// readFile reads a file or directory for sharing.
+// The web interface only understands text, so --legacy sends a file's raw contents instead of a bundle.
+func readFile(path string) ([]byte, error) {
+	if !legacyFormat {
+		data, err := bundle.Pack(path)
+		if err != nil {
+			return nil, fmt.Errorf("read file: %w", err)
+		}
+		return data, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+	if info.IsDir() {
+		return nil, &output.UsageError{Err: fmt.Errorf("directories cannot be shared with --legacy")}
 	}
 
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("%s is not text; binary files cannot be shared with --legacy", path)
+	}
+	return data, nil
+}
+
+// readStdin reads secret from standard input.
+// A terminal on stdin is handled by interactive, so this only reads pipes and redirected files.
+func readStdin() ([]byte, error) {
 	data, err := io.ReadAll(os.Stdin)
 	if err != nil {
-		return "", fmt.Errorf("read stdin: %w", err)
+		return nil, fmt.Errorf("read stdin: %w", err)
 	}
-	return string(data), nil
+	return data, nil
 }
@@ -0,0 +1,109 @@
+package create
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+
+	"github.com/brentdalling/ots-cli/internal/api"
+	"github.com/brentdalling/ots-cli/internal/output"
+)
+
+// resetFlags restores every create flag to its default once the test ends.
+func resetFlags(t *testing.T) {
+	t.Cleanup(func() {
+		CreateCmd.Flags().VisitAll(func(f *pflag.Flag) {
+			f.Value.Set(f.DefValue)
+			f.Changed = false
+		})
+		CreateCmd.SetArgs(nil)
+	})
+}
+
+func TestCreateCmd_RejectsArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--generate-password", "diceware"}, "--generate-password=diceware"},
+		{[]string{"--split", "words"}, "--split=words"},
+		{[]string{"my secret"}, "--text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			resetFlags(t)
+			CreateCmd.SetArgs(tt.args)
+			CreateCmd.SetOut(io.Discard)
+			CreateCmd.SetErr(io.Discard)
+
+			err := CreateCmd.Execute()
+			var usageErr *output.UsageError
+			if !errors.As(err, &usageErr) {
+				t.Fatalf("Execute() error = %v, want a usage error", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Execute() error = %q, want it to mention %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateArgs_ValueAfterEquals(t *testing.T) {
+	resetFlags(t)
+	if err := CreateCmd.ParseFlags([]string{"--generate-password=diceware", "--split=words"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := createArgs(CreateCmd, CreateCmd.Flags().Args()); err != nil {
+		t.Errorf("createArgs() = %v, want nil", err)
+	}
+	if generate != "diceware" || split != splitWords {
+		t.Errorf("generate, split = %q, %q", generate, split)
+	}
+}
+
+func TestCheckReadLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		// configBurn is burn-after-read from the config file
+		configBurn bool
+		wantErr    bool
+		wantBurn   bool
+	}{
+		{"default", nil, false, false, false},
+		{"default burns", nil, true, false, true},
+		{"lowest", []string{"--max-reads=1"}, false, false, false},
+		{"highest", []string{"--max-reads=" + strconv.Itoa(api.MaxReadsLimit)}, false, false, false},
+		{"zero", []string{"--max-reads=0"}, false, true, false},
+		{"too many", []string{"--max-reads=" + strconv.Itoa(api.MaxReadsLimit+1)}, false, true, false},
+		{"burn flag", []string{"--burn-after-read", "--max-reads=3"}, false, true, true},
+		{"burn flag single read", []string{"--burn-after-read", "--max-reads=1"}, false, false, true},
+		{"overrides config burn", []string{"--max-reads=3"}, true, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFlags(t)
+			if err := CreateCmd.ParseFlags(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if tt.configBurn {
+				burnAfterRead = true
+			}
+
+			err := checkReadLimit(CreateCmd)
+			var usageErr *output.UsageError
+			if (err != nil) != tt.wantErr || err != nil && !errors.As(err, &usageErr) {
+				t.Fatalf("checkReadLimit() = %v, want usage error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && burnAfterRead != tt.wantBurn {
+				t.Errorf("burnAfterRead = %v, want %v", burnAfterRead, tt.wantBurn)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package create
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/brentdalling/ots-cli/internal/api"
+	"github.com/brentdalling/ots-cli/internal/config"
+	"github.com/brentdalling/ots-cli/internal/passgen"
+	"github.com/brentdalling/ots-cli/internal/prompt"
+)
+
+// errAborted is returned when the user declines the summary of an interactive create.
+var errAborted = errors.New("aborted, nothing was created")
+
+// interactive asks for the secret and any settings not given as flags when stdin is a terminal.
+// Answers are stored as if the matching flags had been set, so the usual checks apply to them;
+// a password goes straight to the password variable so it is not mistaken for one given on the command line.
+func interactive(cmd *cobra.Command, cfg *config.Config) ([]byte, error) {
+	ctx := cmd.Context()
+	flags := cmd.Flags()
+
+	secret, err := prompt.Secret(ctx, "Enter the secret (input is hidden). Press Enter for a new line and Ctrl-D when done:")
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+
+	passwordSource := "none"
+	if flags.Changed("generate-password") {
+		passwordSource = "generated"
+	} else if flags.Changed("password") {
+		passwordSource = "given with --password"
+	} else {
+		if passwordSource, err = askPassword(cmd); err != nil {
+			return nil, err
+		}
+	}
+
+	if !flags.Changed("expires-in") {
+		if err := askExpiry(cmd); err != nil {
+			return nil, err
+		}
+	}
+
+	if !flags.Changed("max-reads") && !flags.Changed("burn-after-read") {
+		if err := askReads(cmd); err != nil {
+			return nil, err
+		}
+	}
+
+	printSummary(cfg.ServerURL, secret, passwordSource)
+	ok, err := prompt.Confirm(ctx, "Create the secret?", true)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errAborted
+	}
+	return secret, nil
+}
+
+// askPassword offers to protect the secret with a generated password or passphrase, or one the user enters,
+// and reports which was chosen.
+func askPassword(cmd *cobra.Command) (string, error) {
+	for {
+		answer, err := prompt.Line(cmd.Context(), "Password protection: [n]one, [g]enerate a password, generate a [d]iceware passphrase or [e]nter one", "n")
+		if err != nil {
+			return "", err
+		}
+		spec := strconv.Itoa(passgen.DefaultLength)
+		switch strings.ToLower(answer) {
+		case "n", "none":
+			return "none", nil
+		case "d", "diceware":
+			spec = "diceware"
+			fallthrough
+		case "g", "generate":
+			newPassword, entropy, err := generatePassword(spec)
+			if err != nil {
+				return "", err
+			}
+			password, generated = newPassword, true
+			return fmt.Sprintf("generated, about %.0f bits", entropy), nil
+		case "e", "enter":
+			entered, err := prompt.NewPassword(cmd.Context(), "Password: ")
+			if err != nil {
+				return "", err
+			}
+			warnIfWeak(entered)
+			password = entered
+			return "entered", nil
+		}
+		fmt.Fprintln(os.Stderr, "Please answer n, g, d or e.")
+	}
+}
+
+// askExpiry asks how long the secret lives, defaulting to the configured expiry.
+func askExpiry(cmd *cobra.Command) error {
+	for {
+		answer, err := prompt.Line(cmd.Context(), "Expires in (e.g. 1h, 24h, 7d)", expiresIn)
+		if err != nil {
+			return err
+		}
+		if err := config.ValidateExpiresIn(answer); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		return cmd.Flags().Set("expires-in", answer)
+	}
+}
+
+// askReads asks how many times the link can be read.
+func askReads(cmd *cobra.Command) error {
+	for {
+		answer, err := prompt.Line(cmd.Context(), fmt.Sprintf("Number of reads, 1-%d", api.MaxReadsLimit), "1")
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(answer)
+		if err != nil || n < 1 || n > api.MaxReadsLimit {
+			fmt.Fprintf(os.Stderr, "Enter a number between 1 and %d.\n", api.MaxReadsLimit)
+			continue
+		}
+		if n == 1 {
+			// One read is the default, and leaves a burn-after-read setting in effect
+			return nil
+		}
+		// Like --max-reads, more reads override a burn-after-read setting from the config file
+		burnAfterRead = false
+		return cmd.Flags().Set("max-reads", answer)
+	}
+}
+
+// printSummary shows what is about to be uploaded, without the secret or its password.
+func printSummary(server string, secret []byte, passwordSource string) {
+	access := "none"
+	if accessPassword != "" {
+		access = "yes"
+	}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Summary:")
+	fmt.Fprintf(os.Stderr, "  Server:          %s\n", server)
+	fmt.Fprintf(os.Stderr, "  Secret:          %d bytes, %d lines\n", len(secret), bytes.Count(secret, []byte("\n"))+1)
+	fmt.Fprintf(os.Stderr, "  Expires in:      %s\n", expiresIn)
+	fmt.Fprintf(os.Stderr, "  Reads:           %d\n", maxReads)
+	fmt.Fprintf(os.Stderr, "  Password:        %s\n", passwordSource)
+	fmt.Fprintf(os.Stderr, "  Access password: %s\n", access)
+	fmt.Fprintln(os.Stderr)
+}
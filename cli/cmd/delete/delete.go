@@ -0,0 +1,159 @@
+// Package delete provides the command for revoking one-time secrets before they are read.
+package delete
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/brentdalling/ots-cli/cmd/otsclient"
+	"github.com/brentdalling/ots-cli/internal/config"
+	"github.com/brentdalling/ots-cli/internal/history"
+	"github.com/brentdalling/ots-cli/internal/link"
+	"github.com/brentdalling/ots-cli/pkg/ots"
+)
+
+var (
+	serverURL string
+	fromStdin bool
+)
+
+// DeleteCmd is the cobra command for deleting secrets.
+var DeleteCmd = &cobra.Command{
+	Use:   "delete <link-or-id>...",
+	Short: "Delete one-time secrets before they are read",
+	Long: "Permanently delete one or more secrets by link or bare ID.\n" +
+		"Secrets that were already read, expired or deleted are reported as already gone.\n" +
+		"With --stdin (or no arguments and piped input), reads one link or ID per line.",
+	RunE: runDelete,
+}
+
+func init() {
+	DeleteCmd.Flags().StringVarP(&serverURL, "server", "s", "", "Override server URL")
+	DeleteCmd.Flags().BoolVar(&fromStdin, "stdin", false, "Read links or IDs from stdin, one per line")
+}
+
+// runDelete handles the delete command execution.
+// Every target is attempted; the command fails if any deletion failed for a reason other than the secret being gone.
+func runDelete(cmd *cobra.Command, args []string) error {
+	targets := args
+	if fromStdin || (len(args) == 0 && !stdinIsTerminal()) {
+		lines, err := readLines()
+		if err != nil {
+			return fmt.Errorf("read stdin: %w", err)
+		}
+		targets = append(targets, lines...)
+	}
+
+	if len(targets) == 0 {
+		return fmt.Errorf("no secrets given. Pass links or IDs as arguments, or pipe them on stdin")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if serverURL != "" {
+		cfg.ServerURL = serverURL
+	}
+
+	// Only the history knows the parts of a multi-part secret. It is optional, so errors are ignored.
+	store, err := history.Default()
+	var entries []history.Entry
+	if err == nil {
+		entries, _ = store.Load()
+	}
+
+	ctx := cmd.Context()
+	var failed int
+	var deleted []string
+	for _, target := range targets {
+		// After Ctrl-C, stop instead of failing every remaining secret the same way
+		if ctx.Err() != nil {
+			break
+		}
+		id, err := deleteOne(ctx, cfg, entries, target)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "✗ %s: %v\n", target, err)
+			continue
+		}
+		deleted = append(deleted, id)
+	}
+
+	// Deleted secrets, and all their parts, no longer need a history entry
+	if store != nil {
+		_ = store.Remove(deleted...)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d deletions failed", failed, len(targets))
+	}
+	return nil
+}
+
+// deleteOne deletes a single secret, and the parts recorded for it in entries, prints the outcome and returns its ID.
+// Links carry their own server unless --server overrides it; bare IDs use the configured server.
+// If a part cannot be deleted, the error keeps the history entry, so `ots revoke` can retry.
+func deleteOne(ctx context.Context, cfg *config.Config, entries []history.Entry, target string) (string, error) {
+	l, err := link.ParseLinkOrID(target)
+	if err != nil {
+		return "", err
+	}
+
+	server := cfg.ServerURL
+	if serverURL == "" && l.Server != "" {
+		server = l.Server
+	}
+
+	client, err := otsclient.New(cfg, server)
+	if err != nil {
+		return "", err
+	}
+
+	err = client.Delete(ctx, l.ID)
+	gone := errors.Is(err, ots.ErrNotFound)
+	if err != nil && !gone {
+		return "", err
+	}
+
+	entry, _ := history.Find(entries, l.ID)
+	for _, id := range entry.Parts {
+		if err := client.Delete(ctx, id); err != nil && !errors.Is(err, ots.ErrNotFound) {
+			return "", fmt.Errorf("delete part %s: %w", id, err)
+		}
+	}
+
+	if gone {
+		fmt.Printf("- %s already gone (read, expired or deleted)\n", l.ID)
+	} else {
+		fmt.Printf("✓ Deleted %s\n", l.ID)
+	}
+	return l.ID, nil
+}
+
+// readLines reads non-empty lines from stdin.
+func readLines() ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// stdinIsTerminal reports whether stdin is an interactive terminal rather than a pipe.
+func stdinIsTerminal() bool {
+	stat, err := os.Stdin.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
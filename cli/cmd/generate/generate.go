@@ -0,0 +1,88 @@
+// Package generate provides the command for generating passwords and passphrases.
+package generate
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/brentdalling/ots-cli/internal/output"
+	"github.com/brentdalling/ots-cli/internal/passgen"
+)
+
+var (
+	length    int
+	classes   string
+	diceware  bool
+	words     int
+	separator string
+)
+
+// GenerateCmd is the cobra command for generating passwords.
+var GenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a random password or passphrase",
+	Long: "Generate a random password from the chosen character classes, or with --diceware a passphrase\n" +
+		"of common English words. The entropy estimate is printed to stderr.",
+	Args: cobra.NoArgs,
+	RunE: runGenerate,
+}
+
+func init() {
+	GenerateCmd.Flags().IntVarP(&length, "length", "l", passgen.DefaultLength, fmt.Sprintf("Password length, %d-%d", passgen.MinLength, passgen.MaxLength))
+	GenerateCmd.Flags().StringVar(&classes, "classes", "lower,upper,digits,symbols", "Character classes to use: lower, upper, digits, symbols")
+	GenerateCmd.Flags().BoolVarP(&diceware, "diceware", "d", false, "Generate a passphrase of random words instead")
+	GenerateCmd.Flags().IntVarP(&words, "words", "w", passgen.DefaultWords, fmt.Sprintf("Number of words in a passphrase, %d-%d", passgen.MinWords, passgen.MaxWords))
+	GenerateCmd.Flags().StringVar(&separator, "separator", passgen.DefaultSeparator, "Separator between the words of a passphrase")
+}
+
+// generateResult is the JSON form of a generated password.
+type generateResult struct {
+	Password string  `json:"password"`
+	Kind     string  `json:"kind"`
+	Entropy  float64 `json:"entropy"`
+}
+
+// runGenerate handles the generate command execution.
+func runGenerate(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	if diceware && (flags.Changed("length") || flags.Changed("classes")) {
+		return &output.UsageError{Err: fmt.Errorf("--length and --classes cannot be used with --diceware")}
+	}
+	if !diceware && (flags.Changed("words") || flags.Changed("separator")) {
+		return &output.UsageError{Err: fmt.Errorf("--words and --separator require --diceware")}
+	}
+
+	var result generateResult
+	if diceware {
+		phrase, err := passgen.Passphrase(words, separator)
+		if err != nil {
+			return &output.UsageError{Err: err}
+		}
+		result = generateResult{Password: phrase, Kind: "passphrase", Entropy: passgen.PassphraseEntropy(words)}
+	} else {
+		c, err := passgen.ParseClasses(classes)
+		if err != nil {
+			return &output.UsageError{Err: fmt.Errorf("--classes: %w", err)}
+		}
+		password, err := passgen.Password(length, c)
+		if err != nil {
+			return &output.UsageError{Err: err}
+		}
+		result = generateResult{Password: password, Kind: "password", Entropy: passgen.PasswordEntropy(length, c)}
+	}
+	result.Entropy = math.Round(result.Entropy*10) / 10
+
+	switch output.Current() {
+	case output.JSON:
+		return output.PrintJSON(result)
+	case output.Raw:
+		fmt.Println(result.Password)
+		return nil
+	}
+	fmt.Println(result.Password)
+	fmt.Fprintf(os.Stderr, "Entropy: about %.0f bits\n", result.Entropy)
+	return nil
+}
@@ -0,0 +1,62 @@
+// Package list provides the command for listing secrets recorded in the local history.
+package list
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/brentdalling/ots-cli/internal/config"
+	"github.com/brentdalling/ots-cli/internal/history"
+)
+
+// timeFormat is used for creation and expiry times in the listing
+const timeFormat = "2006-01-02 15:04"
+
+// ListCmd is the cobra command for listing recorded secrets.
+var ListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List secrets recorded in the local history",
+	Long: "List secrets created with history enabled, oldest first.\n" +
+		"Expired entries are marked and pruned automatically a day after they expire.\n" +
+		"The server cannot report whether a secret was read without consuming it, so pending means not yet expired or revoked.",
+	Args: cobra.NoArgs,
+	RunE: runList,
+}
+
+// runList handles the list command execution.
+func runList(cmd *cobra.Command, args []string) error {
+	store, err := history.Default()
+	if err != nil {
+		return err
+	}
+
+	entries, err := store.Prune()
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No secrets in history.")
+		if cfg, err := config.Load(); err == nil && !cfg.History {
+			fmt.Println("Record secrets with `ots create --history`, or run `ots config set history true` to record every secret.")
+		}
+		return nil
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tLABEL\tSERVER\tCREATED\tEXPIRES\tREADS\tSTATE")
+	for _, entry := range entries {
+		expires := "never"
+		if entry.ExpiresAt != nil {
+			expires = entry.ExpiresAt.Local().Format(timeFormat)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			entry.ID, entry.Label, entry.Server, entry.CreatedAt.Local().Format(timeFormat), expires, entry.MaxReads, entry.State(now))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,31 @@
+// Package otsclient builds the SDK client the commands talk to the server with.
+// It lives in the command layer so internal/config only produces API options and never depends on pkg/ots.
+package otsclient
+
+import (
+	"github.com/brentdalling/ots-cli/internal/config"
+	"github.com/brentdalling/ots-cli/pkg/ots"
+)
+
+// New creates a client for server with the configured timeout, retries, TLS settings, proxy and headers;
+// see config.ClientOptions.
+func New(cfg *config.Config, server string) (*ots.Client, error) {
+	opts, err := cfg.ClientOptions(server)
+	if err != nil {
+		return nil, err
+	}
+	otsOpts := []ots.Option{
+		ots.WithServer(server),
+		ots.WithTimeout(opts.Timeout),
+		ots.WithRetries(opts.Retry.MaxRetries),
+		ots.WithCAFile(opts.CAFile),
+		ots.WithClientCertificate(opts.ClientCertFile, opts.ClientKeyFile),
+		ots.WithPin(opts.Pin),
+		ots.WithProxy(opts.Proxy),
+		ots.WithHeaders(opts.Headers),
+	}
+	if opts.AllowInsecureHTTP {
+		otsOpts = append(otsOpts, ots.WithInsecureHTTP())
+	}
+	return ots.NewClient(otsOpts...)
+}
@@ -0,0 +1,25 @@
+package redeem
+
+import (
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/brentdalling/ots-cli/internal/clipboard"
+)
+
+// ClearClipboardCmd is the hidden helper that clears a redeemed secret from the clipboard in the background.
+// It is started by redeem with the TTL as its argument and the secret's fingerprint on stdin.
+var ClearClipboardCmd = &cobra.Command{
+	Use:    clipboard.HelperCommand + " <ttl>",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ttl, err := time.ParseDuration(args[0])
+		if err != nil {
+			return err
+		}
+		return clipboard.RunClearer(os.Stdin, ttl)
+	},
+}
@@ -0,0 +1,159 @@
+package redeem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/brentdalling/ots-cli/internal/output"
+	"github.com/brentdalling/ots-cli/internal/prompt"
+)
+
+// Escape sequences switching the terminal to its alternate screen, which keeps no scrollback
+// and is discarded on leaving, and back.
+const (
+	enterAltScreen = "\x1b[?1049h\x1b[H\x1b[2J"
+	leaveAltScreen = "\x1b[2J\x1b[?1049l"
+)
+
+// defaultPager is run by --pager when neither OTS_PAGER nor PAGER is set.
+const defaultPager = "less"
+
+// displayFlags lists the flags choosing how a secret is shown, which exclude each other.
+var displayFlags = []string{"reveal", "alt-screen", "pager", "mask", "quiet"}
+
+// checkDisplayFlags checks the display flags before the read is consumed.
+func checkDisplayFlags(cmd *cobra.Command) error {
+	var chosen []string
+	for _, name := range displayFlags {
+		if cmd.Flags().Changed(name) {
+			chosen = append(chosen, "--"+name)
+		}
+	}
+	if len(chosen) == 0 {
+		return nil
+	}
+	flag := chosen[0]
+	masked = cmd.Flags().Changed("mask")
+	if len(chosen) > 1 {
+		return &output.UsageError{Err: fmt.Errorf("%s cannot be combined", strings.Join(chosen, " and "))}
+	}
+
+	switch {
+	case quiet && output.Current() == output.JSON:
+		return &output.UsageError{Err: fmt.Errorf("--quiet cannot be combined with --output json")}
+	case quiet:
+		return nil
+	case output.Current() != output.Text:
+		return &output.UsageError{Err: fmt.Errorf("%s cannot be combined with --output %s", flag, output.Current())}
+	case outputPath != "":
+		return &output.UsageError{Err: fmt.Errorf("%s cannot be combined with --out-file", flag)}
+	case clipboardOnly:
+		return &output.UsageError{Err: fmt.Errorf("%s cannot be combined with --clipboard-only, which never shows the secret", flag)}
+	case maskChars < 0:
+		return &output.UsageError{Err: fmt.Errorf("--mask must not be negative")}
+	}
+
+	// Showing the secret on the alternate screen, and waiting for keys, needs a terminal at both ends
+	if (reveal || altScreen || usePager) && !term.IsTerminal(int(os.Stdout.Fd())) {
+		return &output.UsageError{Err: fmt.Errorf("%s needs a terminal on stdout; use --quiet or --out-file when redirecting", flag)}
+	}
+	if (reveal || altScreen) && !prompt.IsTerminal() {
+		return &output.UsageError{Err: fmt.Errorf("%s needs a terminal on stdin to wait for a key", flag)}
+	}
+	if usePager {
+		if _, err := pagerCommand(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// showSecret displays a secret in text mode without leaving it in the terminal's scrollback,
+// as chosen with --reveal, --alt-screen, --pager or --mask. It reports false if no such mode was chosen.
+func showSecret(ctx context.Context, plaintext []byte) (bool, error) {
+	switch {
+	case reveal:
+		fmt.Println("Secret retrieved successfully!")
+		if err := prompt.WaitKey(ctx, "Press any key to reveal the secret, or Ctrl-C to skip it."); err != nil {
+			if errors.Is(err, context.Canceled) {
+				fmt.Fprintln(os.Stderr, "The secret was not shown.")
+				return true, nil
+			}
+			return true, err
+		}
+		return true, showOnAltScreen(ctx, plaintext)
+	case altScreen:
+		fmt.Println("Secret retrieved successfully!")
+		return true, showOnAltScreen(ctx, plaintext)
+	case usePager:
+		fmt.Println("Secret retrieved successfully!")
+		return true, page(ctx, plaintext)
+	case masked:
+		fmt.Println("Secret retrieved successfully!")
+		fmt.Println()
+		fmt.Println(output.Mask(string(plaintext), maskChars))
+		return true, nil
+	}
+	return false, nil
+}
+
+// showOnAltScreen shows the secret on the alternate screen until a key is pressed.
+// Leaving the alternate screen wipes it, so the secret is not left behind in the terminal.
+func showOnAltScreen(ctx context.Context, plaintext []byte) error {
+	fmt.Print(enterAltScreen)
+	defer fmt.Print(leaveAltScreen)
+
+	fmt.Println(string(plaintext))
+	fmt.Println()
+	err := prompt.WaitKey(ctx, "Press any key to hide the secret.")
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// pagerCommand returns the pager from OTS_PAGER or PAGER, or less, with its arguments.
+func pagerCommand() ([]string, error) {
+	pager := os.Getenv("OTS_PAGER")
+	if pager == "" {
+		pager = os.Getenv("PAGER")
+	}
+	if pager == "" {
+		pager = defaultPager
+	}
+	args := strings.Fields(pager)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no pager configured")
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return nil, fmt.Errorf("pager %s not found; set OTS_PAGER or PAGER", args[0])
+	}
+	return args, nil
+}
+
+// page shows the secret in the pager, fed through a pipe.
+// Pagers such as less show it on the alternate screen.
+func page(ctx context.Context, plaintext []byte) error {
+	args, err := pagerCommand()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(string(plaintext))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// less keeps no history of what it showed, but make sure it does not record searches either
+	cmd.Env = append(os.Environ(), "LESSHISTFILE=-")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("run pager %s: %w", args[0], err)
+	}
+	return nil
+}
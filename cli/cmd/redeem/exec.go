@@ -0,0 +1,160 @@
+package redeem
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/brentdalling/ots-cli/internal/bundle"
+	"github.com/brentdalling/ots-cli/internal/output"
+)
+
+// envNamePattern matches portable environment variable names.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// redeemArgs accepts a link, optionally followed by -- and a command to run with the secret.
+func redeemArgs(cmd *cobra.Command, args []string) error {
+	dash := cmd.ArgsLenAtDash()
+	if err := checkMaskArg(cmd, args, dash); err != nil {
+		return err
+	}
+	if dash == -1 {
+		return cobra.ExactArgs(1)(cmd, args)
+	}
+	if dash != 1 {
+		return &output.UsageError{Err: fmt.Errorf("expected the link before --, got %d arguments", dash)}
+	}
+	if len(args) == 1 {
+		return &output.UsageError{Err: fmt.Errorf("missing command after --")}
+	}
+	return nil
+}
+
+// checkMaskArg rejects a count given to --mask without =. --mask takes a value only after =,
+// so in "--mask 4" the count is an argument and would be taken for a second link.
+func checkMaskArg(cmd *cobra.Command, args []string, dash int) error {
+	f := cmd.Flags().Lookup("mask")
+	if !f.Changed || f.Value.String() != f.NoOptDefVal {
+		return nil
+	}
+	if dash == -1 {
+		dash = len(args)
+	}
+	if dash < 2 {
+		return nil
+	}
+	for _, arg := range args[:dash] {
+		if _, err := strconv.Atoi(arg); err == nil {
+			return &output.UsageError{Err: fmt.Errorf("unexpected argument %q; --mask takes its value after =, e.g. --mask=%s", arg, arg)}
+		}
+	}
+	return nil
+}
+
+// commandArgs returns the command given after --, if any.
+func commandArgs(cmd *cobra.Command, args []string) []string {
+	if dash := cmd.ArgsLenAtDash(); dash != -1 {
+		return args[dash:]
+	}
+	return nil
+}
+
+// checkExecFlags checks --env and --stdin against the command to run, before the read is consumed.
+// The secret only goes to the command, so it is never copied to the clipboard.
+func checkExecFlags(cmd *cobra.Command, command []string) error {
+	if len(command) == 0 {
+		if envVar != "" || toStdin {
+			return &output.UsageError{Err: fmt.Errorf("--env and --stdin need a command after --, e.g. ots redeem <link> --env TOKEN -- deploy")}
+		}
+		return nil
+	}
+	if envVar == "" && !toStdin {
+		return &output.UsageError{Err: fmt.Errorf("a command after -- needs --env VAR or --stdin to receive the secret")}
+	}
+	if envVar != "" && !envNamePattern.MatchString(envVar) {
+		return &output.UsageError{Err: fmt.Errorf("--env: invalid environment variable name %q", envVar)}
+	}
+
+	for _, name := range append([]string{"out-file", "clipboard-only"}, displayFlags...) {
+		if name != "quiet" && cmd.Flags().Changed(name) {
+			return &output.UsageError{Err: fmt.Errorf("--%s cannot be combined with a command, which receives the secret instead", name)}
+		}
+	}
+	if output.Current() != output.Text {
+		return &output.UsageError{Err: fmt.Errorf("--output %s cannot be combined with a command, which writes its own output", output.Current())}
+	}
+	if _, err := exec.LookPath(command[0]); err != nil {
+		return fmt.Errorf("cannot run %s: %w; the secret was not read", command[0], err)
+	}
+
+	noClipboard = true
+	return nil
+}
+
+// runWithSecret runs command with the secret in the --env variable or on its stdin and waits for it.
+// Signals sent to ots are passed on to the command, and its exit status becomes ours.
+// A shared file is passed as its contents; a directory cannot be passed.
+func runWithSecret(plaintext []byte, command []string) error {
+	secret := plaintext
+	if bundle.IsBundle(plaintext) {
+		b, err := bundle.Unpack(plaintext)
+		if err != nil {
+			return err
+		}
+		if b.Type != bundle.TypeFile {
+			return fmt.Errorf("the secret is %s, which cannot be passed to a command; redeem it with --out-file instead", b.Describe())
+		}
+		secret = b.Data
+	}
+
+	child := exec.Command(command[0], command[1:]...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	child.Env = os.Environ()
+	if envVar != "" {
+		if bytes.IndexByte(secret, 0) >= 0 {
+			return fmt.Errorf("the secret contains NUL bytes and cannot be put in an environment variable; use --stdin")
+		}
+		child.Env = append(child.Env, envVar+"="+string(secret))
+	}
+	if toStdin {
+		child.Stdin = bytes.NewReader(secret)
+	}
+
+	// Catch signals before starting, so none kills ots and leaves the command running unattended
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("run %s: %w", command[0], err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- child.Wait() }()
+
+	for {
+		select {
+		case sig := <-signals:
+			if !deliveredByTerminal(sig) {
+				child.Process.Signal(sig)
+			}
+		case err := <-done:
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				return output.ExitStatus(exitCode(exitErr.ProcessState))
+			}
+			if err != nil {
+				return fmt.Errorf("run %s: %w", command[0], err)
+			}
+			return nil
+		}
+	}
+}
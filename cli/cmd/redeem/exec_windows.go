@@ -0,0 +1,17 @@
+package redeem
+
+import "os"
+
+// forwardedSignals are caught while a command runs with the secret, so Ctrl-C does not stop ots first.
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+// deliveredByTerminal reports whether the command has already received sig from the console,
+// which sends Ctrl-C to every process attached to it.
+func deliveredByTerminal(sig os.Signal) bool {
+	return sig == os.Interrupt
+}
+
+// exitCode returns the exit status of a finished command.
+func exitCode(state *os.ProcessState) int {
+	return state.ExitCode()
+}
@@ -2,146 +2,551 @@
 package redeem
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
-	"syscall"
+	"time"
+	"unicode/utf8"
 
-	"github.com/atotto/clipboard"
-	"github.com/brentdalling/ots-cli/internal/api"
+	"github.com/brentdalling/ots-cli/cmd/otsclient"
+	"github.com/brentdalling/ots-cli/internal/bundle"
+	"github.com/brentdalling/ots-cli/internal/clipboard"
 	"github.com/brentdalling/ots-cli/internal/config"
-	"github.com/brentdalling/ots-cli/internal/crypto"
+	"github.com/brentdalling/ots-cli/internal/link"
+	"github.com/brentdalling/ots-cli/internal/output"
+	"github.com/brentdalling/ots-cli/internal/prompt"
+	"github.com/brentdalling/ots-cli/pkg/ots"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 )
 
 var (
-	password    string
-	noClipboard bool
-	serverURL   string
+	password       string
+	accessPassword string
+	noClipboard    bool
+	clipboardOnly  bool
+	clipboardWait  bool
+	clipboardTTL   time.Duration
+	reveal         bool
+	altScreen      bool
+	usePager       bool
+	maskChars      int
+	masked         bool
+	quiet          bool
+	envVar         string
+	toStdin        bool
+	providedKey    string
+	serverURL      string
+	outputPath     string
 )
 
+// maxAccessAttempts is how many access passwords are prompted for before giving up
+const maxAccessAttempts = 3
+
+// maxKeyAttempts is how many keys are prompted for before giving up
+const maxKeyAttempts = 3
+
 // RedeemCmd is the cobra command for redeeming secrets.
 var RedeemCmd = &cobra.Command{
-	Use:   "redeem <link>",
+	Use:   "redeem <link> [-- command [args...]]",
 	Short: "Redeem a one-time secret",
-	Long:  "Redeem a one-time secret by providing the full link with key",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runRedeem,
+	Long: "Redeem a one-time secret by providing the full link with key.\n" +
+		"With --env or --stdin and a command after --, the secret is handed to the command instead of being printed.",
+	Args: redeemArgs,
+	RunE: runRedeem,
 }
 
 func init() {
 	RedeemCmd.Flags().StringVarP(&password, "password", "p", "", "Password to decrypt the secret")
+	RedeemCmd.Flags().StringVar(&accessPassword, "access-password", "", "Access password the server requires before releasing the secret")
 	RedeemCmd.Flags().BoolVarP(&noClipboard, "no-clipboard", "n", false, "Don't copy secret to clipboard")
+	RedeemCmd.Flags().BoolVar(&clipboardOnly, "clipboard-only", false, "Copy the secret to the clipboard without ever printing it")
+	RedeemCmd.Flags().DurationVar(&clipboardTTL, "clipboard-ttl", 0, "Clear the secret from the clipboard after this long, e.g. 30s (default from the clipboard-ttl setting; 0 keeps it)")
+	RedeemCmd.Flags().BoolVar(&clipboardWait, "clipboard-wait", false, "Wait with a countdown until the clipboard is cleared instead of clearing it in the background")
+	RedeemCmd.Flags().StringVar(&providedKey, "key", "", "Decryption key for a link created with --split, as hex or a word code")
 	RedeemCmd.Flags().StringVarP(&serverURL, "server", "s", "", "Override server URL")
+	RedeemCmd.Flags().StringVarP(&outputPath, "out-file", "o", "", "Write the secret to this path instead of printing it (- for stdout)")
+	RedeemCmd.Flags().BoolVar(&reveal, "reveal", false, "Keep the secret hidden until a key is pressed, then wipe it from the screen")
+	RedeemCmd.Flags().BoolVar(&altScreen, "alt-screen", false, "Show the secret on the terminal's alternate screen, which is wiped when a key is pressed")
+	RedeemCmd.Flags().BoolVar(&usePager, "pager", false, "Show the secret in $OTS_PAGER, $PAGER or less")
+	RedeemCmd.Flags().IntVar(&maskChars, "mask", 0, "Show only the first and last N characters of the secret")
+	RedeemCmd.Flags().Lookup("mask").NoOptDefVal = "4"
+	RedeemCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Write only the secret, with no messages, e.g. to pipe it into another tool")
+	RedeemCmd.Flags().StringVar(&envVar, "env", "", "Run the command after -- with the secret in this environment variable")
+	RedeemCmd.Flags().BoolVar(&toStdin, "stdin", false, "Run the command after -- with the secret on its stdin")
 }
 
 // runRedeem handles the redeem command execution.
-// It extracts the token and key from the URL, retrieves the secret from the server,
+// It extracts the ID and key from the link, retrieves the secret from the server,
 // and decrypts it client-side.
 func runRedeem(cmd *cobra.Command, args []string) error {
-	parsedURL, err := url.Parse(args[0])
+	l, err := link.Parse(args[0])
 	if err != nil {
-		return fmt.Errorf("invalid URL: %w", err)
+		return err
+	}
+	if err := checkKey(l); err != nil {
+		return err
 	}
 
-	token, key, err := extractTokenAndKey(parsedURL)
-	if err != nil {
+	// Check the destination before the read is consumed, since the secret cannot be fetched twice
+	if outputPath == "-" && output.Current() == output.JSON {
+		return &output.UsageError{Err: fmt.Errorf("--out-file - cannot be combined with --output json")}
+	}
+	if err := checkOutputPath(outputPath); err != nil {
+		return err
+	}
+	if err := checkDisplayFlags(cmd); err != nil {
 		return err
 	}
 
-	cfg := config.LoadConfig()
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if err := checkClipboardFlags(cmd, cfg); err != nil {
+		return err
+	}
+	command := commandArgs(cmd, args)
+	if err := checkExecFlags(cmd, command); err != nil {
+		return err
+	}
+	if l.Key == "" && providedKey == "" {
+		if providedKey, err = promptKey(cmd.Context()); err != nil {
+			return err
+		}
+	}
 	if serverURL != "" {
 		cfg.ServerURL = serverURL
-	} else if parsedURL.Scheme != "" && parsedURL.Host != "" {
-		cfg.ServerURL = fmt.Sprintf("%s://%s", parsedURL.Scheme, parsedURL.Host)
+	} else if l.Server != "" {
+		// With profiles configured, only follow links to servers we know about
+		if len(cfg.Profiles) > 0 && !cfg.KnownServer(l.Server) {
+			return fmt.Errorf("link points at %s, which does not match any configured profile (%s)\n\nIf you trust this server, pass --server %s to redeem it anyway",
+				l.Server, strings.Join(cfg.ProfileNames(), ", "), l.Server)
+		}
+		cfg.ServerURL = l.Server
+	}
+
+	client, err := otsclient.New(cfg, cfg.ServerURL)
+	if err != nil {
+		return err
+	}
+
+	var parts int
+	progress := ots.WithProgress(func(index, total int) {
+		parts = total
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "Fetching part %d/%d...\n", index+1, total)
+		}
+	})
+
+	env, err := retrieveSecret(cmd.Context(), client, args[0], accessPassword, progress, ots.WithKey(providedKey))
+	if err != nil {
+		return err
 	}
 
-	client := api.NewClient(cfg.ServerURL)
-	resp, err := client.RetrieveSecret(token)
+	plaintext, err := decryptSecret(cmd.Context(), env, password)
 	if err != nil {
-		return fmt.Errorf("retrieve secret: %w", err)
+		return err
+	}
+	if len(command) > 0 {
+		return runWithSecret(plaintext, command)
 	}
 
-	enc := &crypto.EncryptedSecret{
-		Ciphertext: resp.Ciphertext,
-		IV:         resp.IV,
-		Salt:       resp.Salt,
-		Key:        key,
+	result := &redeemResult{
+		ID:                env.ID,
+		Server:            cfg.ServerURL,
+		Parts:             parts,
+		PasswordProtected: env.PasswordProtected,
+		Version:           env.Version,
+		KDF:               env.KDF,
 	}
 
-	plaintext, err := decryptSecret(enc, password)
+	switch {
+	case bundle.IsBundle(plaintext):
+		err = saveBundle(plaintext, outputPath, result)
+	case outputPath != "":
+		err = saveSecret(plaintext, outputPath, result)
+	default:
+		return outputSecret(cmd.Context(), plaintext, result)
+	}
 	if err != nil {
-		return fmt.Errorf("decrypt secret: %w", err)
+		return err
 	}
 
-	outputSecret(plaintext, noClipboard)
+	if output.Current() == output.JSON {
+		return output.PrintJSON(result)
+	}
 	return nil
 }
 
-// extractTokenAndKey extracts the server-generated token and encryption key from a URL.
-// Expected format: /s/{token}?key={encryptionKey}
-func extractTokenAndKey(parsedURL *url.URL) (string, string, error) {
-	path := strings.TrimPrefix(parsedURL.Path, "/")
-	parts := strings.Split(path, "/")
+// redeemResult is the JSON form of a redeemed secret.
+// Plain secrets carry Plaintext; files, directories and secrets written with --out-file carry Path.
+type redeemResult struct {
+	ID     string `json:"id"`
+	Server string `json:"server"`
+	// Plaintext is the secret, base64-encoded if Encoding is "base64"
+	Plaintext string `json:"plaintext,omitempty"`
+	Encoding  string `json:"encoding,omitempty"`
+	Path      string `json:"path,omitempty"`
+	// Type and Name describe a shared file or directory
+	Type              string `json:"type,omitempty"`
+	Name              string `json:"name,omitempty"`
+	Size              int    `json:"size"`
+	Parts             int    `json:"parts,omitempty"`
+	PasswordProtected bool   `json:"passwordProtected"`
+	Version           int    `json:"version"`
+	KDF               string `json:"kdf"`
+	CopiedToClipboard bool   `json:"copiedToClipboard"`
+	// ClipboardClearAfter is how long the secret stays on the clipboard, if it is cleared
+	ClipboardClearAfter string `json:"clipboardClearAfter,omitempty"`
+}
 
-	if len(parts) < 2 || parts[0] != "s" {
-		return "", "", fmt.Errorf("invalid link format: expected /s/:token")
+// checkKey checks that the key comes from exactly one of the link and --key, before the read is consumed.
+// A link created with --split has none; its key is then asked for if there is a terminal.
+func checkKey(l *link.Link) error {
+	switch {
+	case providedKey != "" && l.Key != "":
+		return &output.UsageError{Err: fmt.Errorf("--key cannot be used with a link that contains a key")}
+	case providedKey != "":
+		key, err := link.ParseKey(providedKey)
+		if err != nil {
+			return &output.UsageError{Err: fmt.Errorf("--key: %w", err)}
+		}
+		providedKey = key
+	case l.Key == "" && !prompt.IsTerminal():
+		return fmt.Errorf("%w (pass the key of a link created with --split with --key, or run in a terminal)", ots.ErrMissingKey)
 	}
+	return nil
+}
 
-	token := parts[1]
-	if token == "" {
-		return "", "", fmt.Errorf("missing token in URL")
+// promptKey asks for the key of a link created with --split, as hex or a word code,
+// until one is valid. It is checked here, as a wrong key would consume the read for nothing.
+func promptKey(ctx context.Context) (string, error) {
+	fmt.Fprintln(os.Stderr, "The link has no key; it was sent separately.")
+	for attempt := 1; ; attempt++ {
+		input, err := prompt.Password(ctx, "Enter key: ")
+		if err != nil {
+			return "", err
+		}
+		key, err := link.ParseKey(input)
+		if err == nil {
+			return key, nil
+		}
+		if attempt >= maxKeyAttempts {
+			return "", err
+		}
+		fmt.Fprintf(os.Stderr, "%v, try again.\n", err)
 	}
+}
 
-	key := parsedURL.Query().Get("key")
-	if key == "" {
-		return "", "", fmt.Errorf("missing key parameter in URL")
-	}
+// retrieveSecret fetches the secret, presenting the access password if one is given.
+// If the server demands an access password and none was given, prompts the user if running in a terminal.
+// A refused access password does not consume a read, so a mistyped one can be retried.
+func retrieveSecret(ctx context.Context, client *ots.Client, rawLink, providedPassword string, opts ...ots.Option) (*ots.Envelope, error) {
+	accessPassword := providedPassword
+	for attempt := 1; ; attempt++ {
+		env, err := client.Fetch(ctx, rawLink, append(opts, ots.WithAccessPassword(accessPassword))...)
+		if !errors.Is(err, ots.ErrAccessPasswordRequired) && !errors.Is(err, ots.ErrAccessDenied) {
+			return env, err
+		}
+
+		if providedPassword != "" || attempt > maxAccessAttempts {
+			return nil, err
+		}
+		if !prompt.IsTerminal() {
+			return nil, fmt.Errorf("retrieve secret: %w (use --access-password flag or run in terminal)", ots.ErrAccessPasswordRequired)
+		}
+		if errors.Is(err, ots.ErrAccessDenied) {
+			fmt.Fprintln(os.Stderr, "Wrong access password, try again.")
+		}
 
-	return token, key, nil
+		accessPassword, err = prompt.Password(ctx, "Enter access password: ")
+		if err != nil {
+			return nil, err
+		}
+	}
 }
 
 // decryptSecret decrypts the secret using the provided password.
 // If password is required but not provided, prompts the user if running in a terminal.
-func decryptSecret(enc *crypto.EncryptedSecret, providedPassword string) (string, error) {
-	plaintext, err := crypto.DecryptSecret(enc, providedPassword)
-	if err != nil {
-		// If password required and not provided, try to prompt if in terminal
-		if err == crypto.ErrPasswordRequired && providedPassword == "" {
-			if term.IsTerminal(int(syscall.Stdin)) {
-				return promptAndDecrypt(enc)
-			}
-			return "", fmt.Errorf("password required (use --password flag or run in terminal)")
+func decryptSecret(ctx context.Context, env *ots.Envelope, providedPassword string) ([]byte, error) {
+	plaintext, err := env.Open(ctx, providedPassword)
+	if err == nil {
+		return plaintext, nil
+	}
+
+	// A corrupted part of a large secret is reported as such, not as a wrong password
+	var partial *ots.PartialError
+	switch {
+	case errors.Is(err, ots.ErrPasswordRequired) && providedPassword == "":
+		if prompt.IsTerminal() {
+			return promptAndDecrypt(ctx, env)
 		}
-		return "", err
+		return nil, fmt.Errorf("decrypt secret: %w (use --password flag or run in terminal)", ots.ErrPasswordRequired)
+	case errors.Is(err, ots.ErrDecryptionFailed) && providedPassword != "" && !errors.As(err, &partial):
+		return nil, fmt.Errorf("decrypt secret: wrong password or corrupted secret: %w", ots.ErrDecryptionFailed)
 	}
-	return plaintext, nil
+	return nil, err
 }
 
 // promptAndDecrypt prompts the user for a password and decrypts the secret.
-func promptAndDecrypt(enc *crypto.EncryptedSecret) (string, error) {
-	fmt.Print("Enter password: ")
-	defer fmt.Println()
+func promptAndDecrypt(ctx context.Context, env *ots.Envelope) ([]byte, error) {
+	entered, err := prompt.Password(ctx, "Enter password: ")
+	if err != nil {
+		return nil, err
+	}
+
+	return env.Open(ctx, entered)
+}
+
+// checkOutputPath rejects an --out-file destination that would have to be overwritten.
+// Existing directories are fine: bundles are written inside them under their original name.
+func checkOutputPath(path string) error {
+	if path == "" || path == "-" {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("check output path: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("output path %s already exists; refusing to overwrite", path)
+	}
+	return nil
+}
 
-	passwordBytes, err := term.ReadPassword(int(syscall.Stdin))
+// saveBundle restores a shared file or directory. It is extracted under its original name
+// only into --out-file. Otherwise a file is written to stdout, as its bytes with --output raw
+// or when redirected, in the JSON result with --output json, or as text on a terminal.
+// A directory always needs --out-file.
+func saveBundle(data []byte, path string, result *redeemResult) error {
+	b, err := bundle.Unpack(data)
 	if err != nil {
-		return "", fmt.Errorf("read password: %w", err)
+		return fmt.Errorf("unpack file: %w", err)
 	}
+	result.Type = b.Type
+	result.Name = b.Name
+	result.Size = len(b.Data)
 
-	return crypto.DecryptSecret(enc, string(passwordBytes))
+	if path != "" && path != "-" {
+		written, err := b.Extract(path)
+		if err != nil {
+			return fmt.Errorf("save %s: %w", b.Describe(), err)
+		}
+		result.Path = written
+
+		if output.Current() != output.JSON && !quiet {
+			fmt.Fprintf(os.Stderr, "Secret %s saved to %s\n", b.Describe(), written)
+		}
+		return nil
+	}
+
+	if err := checkBundleFlags(b); err != nil {
+		return err
+	}
+	if b.Type != bundle.TypeFile {
+		return &output.UsageError{Err: fmt.Errorf("the secret is a %s, which cannot be written to stdout; use --out-file <dir>", b.Describe())}
+	}
+
+	switch {
+	case output.Current() == output.JSON:
+		if utf8.Valid(b.Data) {
+			result.Plaintext, result.Encoding = string(b.Data), "utf-8"
+		} else {
+			result.Plaintext, result.Encoding = base64.StdEncoding.EncodeToString(b.Data), "base64"
+		}
+		return nil
+	case path == "" && output.Current() == output.Text && term.IsTerminal(int(os.Stdout.Fd())):
+		if !utf8.Valid(b.Data) {
+			return fmt.Errorf("the secret is a binary %s, which is not shown on a terminal; use --out-file <path> or redirect stdout", b.Describe())
+		}
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "Secret %s:\n", b.Describe())
+		}
+	}
+	_, err = os.Stdout.Write(b.Data)
+	return err
+}
+
+// checkBundleFlags rejects the display and clipboard flags for a shared file or directory,
+// which is written out as it is instead of being shown or copied.
+func checkBundleFlags(b *bundle.Bundle) error {
+	var flag string
+	switch {
+	case reveal:
+		flag = "--reveal"
+	case altScreen:
+		flag = "--alt-screen"
+	case usePager:
+		flag = "--pager"
+	case masked:
+		flag = "--mask"
+	case clipboardOnly:
+		flag = "--clipboard-only"
+	default:
+		return nil
+	}
+	return &output.UsageError{Err: fmt.Errorf("%s cannot be used for a shared %s; redeem it with --out-file <path> or --output raw", flag, b.Describe())}
 }
 
-// outputSecret prints the decrypted secret and optionally copies it to clipboard.
-func outputSecret(plaintext string, noClipboard bool) {
-	fmt.Println("Secret retrieved successfully!")
-	fmt.Println()
-	fmt.Println(plaintext)
+// saveSecret writes a plain secret to path with owner-only permissions, or to stdout for "-".
+func saveSecret(plaintext []byte, path string, result *redeemResult) error {
+	result.Size = len(plaintext)
+	if path == "-" {
+		_, err := os.Stdout.Write(plaintext)
+		return err
+	}
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = bundle.UniquePath(filepath.Join(path, "secret.txt"))
+	}
+	if err := bundle.WriteFile(path, plaintext); err != nil {
+		return fmt.Errorf("save secret: %w", err)
+	}
+	result.Path = path
+
+	if output.Current() != output.JSON && !quiet {
+		fmt.Fprintf(os.Stderr, "Secret saved to %s\n", path)
+	}
+	return nil
+}
+
+// checkClipboardFlags applies the clipboard settings and checks the clipboard flags,
+// before the read is consumed, since a secret that cannot be shown is lost.
+func checkClipboardFlags(cmd *cobra.Command, cfg *config.Config) error {
+	flags := cmd.Flags()
+	if !flags.Changed("no-clipboard") {
+		noClipboard = !cfg.Clipboard
+	}
+	if !flags.Changed("clipboard-ttl") {
+		clipboardTTL = cfg.ClipboardTTL
+	} else if clipboardTTL < 0 {
+		return &output.UsageError{Err: fmt.Errorf("--clipboard-ttl must not be negative")}
+	}
+
+	if clipboardOnly {
+		switch {
+		case flags.Changed("no-clipboard") && noClipboard:
+			return &output.UsageError{Err: fmt.Errorf("--clipboard-only cannot be combined with --no-clipboard")}
+		case outputPath != "":
+			return &output.UsageError{Err: fmt.Errorf("--clipboard-only cannot be combined with --out-file")}
+		case output.Current() == output.Raw:
+			return &output.UsageError{Err: fmt.Errorf("--clipboard-only cannot be combined with --output raw, which prints only the secret")}
+		case !clipboard.Available():
+			return fmt.Errorf("--clipboard-only: %w (install xclip, xsel or wl-clipboard on Linux); the secret was not read", clipboard.ErrUnavailable)
+		}
+		noClipboard = false
+	}
+	if clipboardWait && clipboardTTL == 0 {
+		return &output.UsageError{Err: fmt.Errorf("--clipboard-wait requires --clipboard-ttl or the clipboard-ttl setting")}
+	}
+	return nil
+}
 
+// outputSecret prints the decrypted secret in the selected format and optionally copies it to clipboard.
+// Raw output is the secret's bytes exactly, with no trailing newline. With --clipboard-only the secret is
+// only copied, and with a clipboard TTL it is cleared again once the TTL has passed.
+func outputSecret(ctx context.Context, plaintext []byte, result *redeemResult) error {
+	text := string(plaintext)
 	if !noClipboard {
-		if err := clipboard.WriteAll(plaintext); err == nil {
+		err := clipboard.Copy(text)
+		if err != nil && clipboardOnly {
+			return fmt.Errorf("copy the secret to the clipboard: %w; it is not shown because of --clipboard-only", err)
+		}
+		result.CopiedToClipboard = err == nil
+	}
+	clearing := result.CopiedToClipboard && clipboardTTL > 0
+	if clearing {
+		result.ClipboardClearAfter = clipboardTTL.String()
+	}
+
+	if err := printSecret(ctx, plaintext, result); err != nil {
+		return err
+	}
+	if !clearing {
+		return nil
+	}
+
+	if clipboardWait {
+		cleared, err := clipboard.Countdown(ctx, os.Stderr, term.IsTerminal(int(os.Stderr.Fd())), text, clipboardTTL)
+		if err != nil {
+			return err
+		}
+		if cleared {
+			fmt.Fprintln(os.Stderr, "✓ Clipboard cleared")
+		} else {
+			fmt.Fprintln(os.Stderr, "Clipboard left alone: it no longer holds the secret")
+		}
+		return nil
+	}
+	if err := clipboard.StartClearer(text, clipboardTTL); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: the clipboard will not be cleared: %v\n", err)
+	}
+	return nil
+}
+
+// printSecret prints the secret, or with --clipboard-only only that it was copied, in the selected format
+// and display mode.
+func printSecret(ctx context.Context, plaintext []byte, result *redeemResult) error {
+	switch output.Current() {
+	case output.JSON:
+		result.Size = len(plaintext)
+		if clipboardOnly {
+			return output.PrintJSON(result)
+		}
+		if utf8.Valid(plaintext) {
+			result.Plaintext = string(plaintext)
+			result.Encoding = "utf-8"
+		} else {
+			result.Plaintext = base64.StdEncoding.EncodeToString(plaintext)
+			result.Encoding = "base64"
+		}
+		return output.PrintJSON(result)
+	case output.Raw:
+		_, err := os.Stdout.Write(plaintext)
+		return err
+	}
+	if quiet {
+		_, err := os.Stdout.Write(plaintext)
+		return err
+	}
+
+	shown, err := showSecret(ctx, plaintext)
+	if err != nil {
+		return err
+	}
+	if !shown {
+		fmt.Println("Secret retrieved successfully!")
+		if !clipboardOnly {
 			fmt.Println()
+			fmt.Println(string(plaintext))
+		}
+	}
+
+	if result.CopiedToClipboard {
+		fmt.Println()
+		if result.ClipboardClearAfter != "" {
+			fmt.Printf("✓ Copied to clipboard (cleared in %s)\n", result.ClipboardClearAfter)
+		} else {
 			fmt.Println("✓ Copied to clipboard")
 		}
 	}
+	return nil
+}
+
+// UseLegacyOutputPath takes path, given with --output, as --out-file, which it was named before
+// --output became the global format flag. Format names keep their new meaning.
+func UseLegacyOutputPath(cmd *cobra.Command, path string) error {
+	if cmd.Flags().Changed("out-file") {
+		return fmt.Errorf("invalid --output %q: expected json, text or raw", path)
+	}
+	fmt.Fprintf(os.Stderr, "Flag --output %s is deprecated for the destination file, use --out-file %s instead\n", path, path)
+	return cmd.Flags().Set("out-file", path)
 }
@@ -0,0 +1,329 @@
+package redeem
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+
+	"github.com/brentdalling/ots-cli/internal/bundle"
+	"github.com/brentdalling/ots-cli/internal/link"
+	"github.com/brentdalling/ots-cli/internal/output"
+	"github.com/brentdalling/ots-cli/internal/prompt"
+	"github.com/brentdalling/ots-cli/pkg/ots"
+)
+
+// resetFlags restores every redeem flag and the output format to their defaults once the test ends.
+func resetFlags(t *testing.T) {
+	t.Cleanup(func() {
+		RedeemCmd.Flags().VisitAll(func(f *pflag.Flag) {
+			f.Value.Set(f.DefValue)
+			f.Changed = false
+		})
+		masked = false
+		output.Set(output.Text)
+	})
+}
+
+// parseFlags parses args as redeem flags, with format as --output.
+func parseFlags(t *testing.T, format output.Format, args ...string) {
+	t.Helper()
+	resetFlags(t)
+	if err := RedeemCmd.ParseFlags(args); err != nil {
+		t.Fatal(err)
+	}
+	output.Set(format)
+}
+
+// wantUsageError fails the test unless err is a usage error, or nil when want is false.
+func wantUsageError(t *testing.T, err error, want bool) {
+	t.Helper()
+	var usageErr *output.UsageError
+	if (err != nil) != want || err != nil && !errors.As(err, &usageErr) {
+		t.Errorf("error = %v, want usage error %v", err, want)
+	}
+}
+
+func TestCheckDisplayFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  output.Format
+		args    []string
+		wantErr bool
+	}{
+		{"none", output.Text, nil, false},
+		{"mask", output.Text, []string{"--mask"}, false},
+		{"mask with count", output.Text, []string{"--mask=2"}, false},
+		{"quiet", output.Text, []string{"--quiet"}, false},
+		{"quiet raw", output.Raw, []string{"--quiet"}, false},
+		{"two modes", output.Text, []string{"--mask", "--pager"}, true},
+		{"quiet json", output.JSON, []string{"--quiet"}, true},
+		{"mask json", output.JSON, []string{"--mask"}, true},
+		{"mask out-file", output.Text, []string{"--mask", "--out-file=secret.txt"}, true},
+		{"mask clipboard-only", output.Text, []string{"--mask", "--clipboard-only"}, true},
+		{"negative mask", output.Text, []string{"--mask=-1"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseFlags(t, tt.format, tt.args...)
+			wantUsageError(t, checkDisplayFlags(RedeemCmd), tt.wantErr)
+		})
+	}
+}
+
+func TestCheckDisplayFlags_Masked(t *testing.T) {
+	parseFlags(t, output.Text, "--mask")
+	if err := checkDisplayFlags(RedeemCmd); err != nil || !masked || maskChars != 4 {
+		t.Errorf("checkDisplayFlags() = %v, masked %v with %d characters, want 4", err, masked, maskChars)
+	}
+}
+
+func TestCheckDisplayFlags_NeedsTerminal(t *testing.T) {
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		t.Skip("stdout is a terminal")
+	}
+	for _, flag := range []string{"--reveal", "--alt-screen", "--pager"} {
+		t.Run(flag, func(t *testing.T) {
+			parseFlags(t, output.Text, flag)
+			wantUsageError(t, checkDisplayFlags(RedeemCmd), true)
+		})
+	}
+}
+
+func TestCheckExecFlags(t *testing.T) {
+	// The test binary is a command that is sure to exist
+	command := []string{os.Args[0], "-test.run=none"}
+
+	tests := []struct {
+		name    string
+		format  output.Format
+		args    []string
+		command []string
+		wantErr bool
+	}{
+		{"no command", output.Text, nil, nil, false},
+		{"env", output.Text, []string{"--env=TOKEN"}, command, false},
+		{"stdin", output.Text, []string{"--stdin"}, command, false},
+		{"quiet", output.Text, []string{"--stdin", "--quiet"}, command, false},
+		{"env without command", output.Text, []string{"--env=TOKEN"}, nil, true},
+		{"stdin without command", output.Text, []string{"--stdin"}, nil, true},
+		{"command without env", output.Text, nil, command, true},
+		{"invalid env name", output.Text, []string{"--env=1TOKEN"}, command, true},
+		{"display mode", output.Text, []string{"--env=TOKEN", "--pager"}, command, true},
+		{"out-file", output.Text, []string{"--env=TOKEN", "--out-file=secret.txt"}, command, true},
+		{"clipboard-only", output.Text, []string{"--env=TOKEN", "--clipboard-only"}, command, true},
+		{"json", output.JSON, []string{"--env=TOKEN"}, command, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseFlags(t, tt.format, tt.args...)
+			err := checkExecFlags(RedeemCmd, tt.command)
+			wantUsageError(t, err, tt.wantErr)
+			if err == nil && len(tt.command) > 0 && !noClipboard {
+				t.Error("a secret handed to a command should not be copied to the clipboard")
+			}
+		})
+	}
+}
+
+func TestCheckExecFlags_MissingCommand(t *testing.T) {
+	parseFlags(t, output.Text, "--env=TOKEN")
+	err := checkExecFlags(RedeemCmd, []string{"ots-test-no-such-command"})
+	var usageErr *output.UsageError
+	if err == nil || errors.As(err, &usageErr) {
+		t.Errorf("checkExecFlags() = %v, want an error that is not a usage error", err)
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	const hexKey = "00112233445566778899aabbccddeeff00112233445566778899aabbccddeeff"
+
+	t.Run("link and flag", func(t *testing.T) {
+		parseFlags(t, output.Text, "--key="+hexKey)
+		wantUsageError(t, checkKey(&link.Link{ID: "abc", Key: hexKey}), true)
+	})
+	t.Run("link", func(t *testing.T) {
+		parseFlags(t, output.Text)
+		wantUsageError(t, checkKey(&link.Link{ID: "abc", Key: hexKey}), false)
+	})
+	t.Run("flag", func(t *testing.T) {
+		parseFlags(t, output.Text, "--key="+strings.ToUpper(hexKey))
+		wantUsageError(t, checkKey(&link.Link{ID: "abc"}), false)
+		if providedKey != hexKey {
+			t.Errorf("providedKey = %q, want %q", providedKey, hexKey)
+		}
+	})
+	t.Run("invalid flag", func(t *testing.T) {
+		parseFlags(t, output.Text, "--key=not-a-key")
+		wantUsageError(t, checkKey(&link.Link{ID: "abc"}), true)
+	})
+	t.Run("missing", func(t *testing.T) {
+		if prompt.IsTerminal() {
+			t.Skip("stdin is a terminal, so the key would be asked for")
+		}
+		parseFlags(t, output.Text)
+		if err := checkKey(&link.Link{ID: "abc"}); !errors.Is(err, ots.ErrMissingKey) {
+			t.Errorf("checkKey() = %v, want ErrMissingKey", err)
+		}
+	})
+}
+
+func TestRedeemArgs_MaskCount(t *testing.T) {
+	const l = "https://ots.example.com/s/01ABC?key=ff"
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{[]string{l, "--mask", "4"}, true},
+		{[]string{"--mask", "2", l}, true},
+		{[]string{l, "--mask=4"}, false},
+		{[]string{l, "--mask"}, false},
+		// Parsing never resets the position of --, so these come last
+		{[]string{l, "--mask", "4", "--", "deploy"}, true},
+		{[]string{l, "--env=N", "--", "sleep", "4"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			parseFlags(t, output.Text, tt.args...)
+			err := redeemArgs(RedeemCmd, RedeemCmd.Flags().Args())
+			wantUsageError(t, err, tt.wantErr)
+			if err != nil && !strings.Contains(err.Error(), "--mask=") {
+				t.Errorf("redeemArgs() = %v, want it to suggest --mask=N", err)
+			}
+		})
+	}
+}
+
+// packFile packs a file holding content as a bundle, as `ots create --file` does.
+func packFile(t *testing.T, name, content string) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	data, err := bundle.Pack(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// captureStdout returns what fn writes to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestSaveBundle_FileToStdout(t *testing.T) {
+	const cert = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+	data := packFile(t, "cert.pem", cert)
+	cwd := t.TempDir()
+	t.Chdir(cwd)
+
+	parseFlags(t, output.Raw)
+	var err error
+	out := captureStdout(t, func() { err = saveBundle(data, "", &redeemResult{}) })
+	if err != nil {
+		t.Fatalf("saveBundle() failed: %v", err)
+	}
+	if out != cert {
+		t.Errorf("stdout = %q, want the file contents %q", out, cert)
+	}
+	if entries, _ := os.ReadDir(cwd); len(entries) != 0 {
+		t.Errorf("nothing should be written to the current directory, found %d entries", len(entries))
+	}
+}
+
+func TestSaveBundle_JSON(t *testing.T) {
+	data := packFile(t, "db.env", "PASSWORD=hunter2\n")
+	t.Chdir(t.TempDir())
+
+	parseFlags(t, output.JSON)
+	result := &redeemResult{}
+	out := captureStdout(t, func() {
+		if err := saveBundle(data, "", result); err != nil {
+			t.Errorf("saveBundle() failed: %v", err)
+		}
+	})
+	if out != "" || result.Plaintext != "PASSWORD=hunter2\n" || result.Encoding != "utf-8" || result.Name != "db.env" || result.Path != "" {
+		t.Errorf("stdout = %q, result = %+v", out, result)
+	}
+}
+
+func TestSaveBundle_OutFile(t *testing.T) {
+	data := packFile(t, "cert.pem", "contents")
+	dir := t.TempDir()
+
+	parseFlags(t, output.Raw, "--out-file="+dir)
+	result := &redeemResult{}
+	out := captureStdout(t, func() {
+		if err := saveBundle(data, dir, result); err != nil {
+			t.Errorf("saveBundle() failed: %v", err)
+		}
+	})
+	if got, err := os.ReadFile(filepath.Join(dir, "cert.pem")); err != nil || string(got) != "contents" || out != "" {
+		t.Errorf("saved %q, %v; stdout = %q", got, err, out)
+	}
+}
+
+func TestSaveBundle_Rejects(t *testing.T) {
+	file := packFile(t, "cert.pem", "contents")
+	dirPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dirPath, "a.txt"), []byte("a"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := bundle.Pack(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format output.Format
+		args   []string
+	}{
+		{"directory", dir, output.Raw, nil},
+		{"mask", file, output.Text, []string{"--mask"}},
+		{"pager", file, output.Text, []string{"--pager"}},
+		{"clipboard-only", file, output.Text, []string{"--clipboard-only"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cwd := t.TempDir()
+			t.Chdir(cwd)
+			parseFlags(t, tt.format, tt.args...)
+			masked = RedeemCmd.Flags().Changed("mask")
+
+			var err error
+			out := captureStdout(t, func() { err = saveBundle(tt.data, "", &redeemResult{}) })
+			wantUsageError(t, err, true)
+			if entries, _ := os.ReadDir(cwd); out != "" || len(entries) != 0 {
+				t.Errorf("stdout = %q and %d entries written, want nothing", out, len(entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// Package revoke provides the command for deleting secrets recorded in the local history.
+package revoke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/brentdalling/ots-cli/cmd/otsclient"
+	"github.com/brentdalling/ots-cli/internal/config"
+	"github.com/brentdalling/ots-cli/internal/history"
+	"github.com/brentdalling/ots-cli/internal/link"
+	"github.com/brentdalling/ots-cli/pkg/ots"
+)
+
+var (
+	all       bool
+	withLabel string
+	dryRun    bool
+)
+
+// RevokeCmd is the cobra command for revoking recorded secrets.
+var RevokeCmd = &cobra.Command{
+	Use:   "revoke [link-or-id...]",
+	Short: "Delete secrets recorded in the local history",
+	Long: "Delete recorded secrets from their servers and drop them from the local history.\n" +
+		"Name secrets by link or ID, or select every pending secret with --all or by --label.\n" +
+		"All parts of a multi-part secret are deleted.",
+	RunE: runRevoke,
+}
+
+func init() {
+	RevokeCmd.Flags().BoolVar(&all, "all", false, "Revoke every pending secret in the history")
+	RevokeCmd.Flags().StringVar(&withLabel, "label", "", "Revoke pending secrets with this label")
+	RevokeCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be revoked without deleting anything")
+}
+
+// runRevoke handles the revoke command execution.
+// Every selected secret is attempted; entries are dropped from the history once the server no longer has them.
+func runRevoke(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 && !all && withLabel == "" {
+		return fmt.Errorf("nothing to revoke. Pass links or IDs, --label, or --all")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	store, err := history.Default()
+	if err != nil {
+		return err
+	}
+
+	entries, err := store.Prune()
+	if err != nil {
+		return err
+	}
+
+	selected, err := selectEntries(entries, args)
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		fmt.Println("No pending secrets match.")
+		return nil
+	}
+
+	ctx := cmd.Context()
+	var revoked []string
+	var failed int
+	for _, entry := range selected {
+		// After Ctrl-C, stop instead of failing every remaining secret the same way
+		if ctx.Err() != nil {
+			break
+		}
+		if dryRun {
+			fmt.Printf("Would revoke %s\n", describe(entry))
+			continue
+		}
+
+		client, err := otsclient.New(cfg, entry.Server)
+		if err != nil {
+			return err
+		}
+
+		gone, err := revoke(ctx, client, entry)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(os.Stderr, "✗ %s: %v\n", describe(entry), err)
+			continue
+		case gone:
+			fmt.Printf("- %s already gone (read, expired or deleted)\n", describe(entry))
+		default:
+			fmt.Printf("✓ Revoked %s\n", describe(entry))
+		}
+		revoked = append(revoked, entry.ID)
+	}
+
+	if err := store.Remove(revoked...); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d revocations failed", failed, len(selected))
+	}
+	return nil
+}
+
+// selectEntries picks the named entries, or the pending entries matching --all or --label.
+// Named secrets must be in the history, since only the history knows their server and parts.
+func selectEntries(entries []history.Entry, args []string) ([]history.Entry, error) {
+	var selected []history.Entry
+	for _, arg := range args {
+		l, err := link.ParseLinkOrID(arg)
+		if err != nil {
+			return nil, err
+		}
+		entry, ok := history.Find(entries, l.ID)
+		if !ok {
+			return nil, fmt.Errorf("%s is not in the local history; use `ots delete` for unrecorded secrets", l.ID)
+		}
+		selected = append(selected, entry)
+	}
+
+	if all || withLabel != "" {
+		now := time.Now()
+		for _, entry := range entries {
+			if entry.Expired(now) || (withLabel != "" && entry.Label != withLabel) {
+				continue
+			}
+			if _, dup := history.Find(selected, entry.ID); !dup {
+				selected = append(selected, entry)
+			}
+		}
+	}
+	return selected, nil
+}
+
+// revoke deletes a secret and any chunk parts from its server.
+// gone reports that the secret itself no longer existed.
+func revoke(ctx context.Context, client *ots.Client, entry history.Entry) (gone bool, err error) {
+	err = client.Delete(ctx, entry.ID)
+	if errors.Is(err, ots.ErrNotFound) {
+		gone, err = true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, id := range entry.Parts {
+		if err := client.Delete(ctx, id); err != nil && !errors.Is(err, ots.ErrNotFound) {
+			return false, fmt.Errorf("delete part %s: %w", id, err)
+		}
+	}
+	return gone, nil
+}
+
+// describe names an entry by ID and label.
+func describe(entry history.Entry) string {
+	if entry.Label == "" {
+		return entry.ID
+	}
+	return fmt.Sprintf("%s (%s)", entry.ID, entry.Label)
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/brentdalling/ots-cli/internal/output"
+)
+
+func TestArgumentErrors_ExitUsage(t *testing.T) {
+	tests := [][]string{
+		{"redeem"},
+		{"redeem", "https://ots.example.com/s/01ABC?key=ff", "extra"},
+		{"status"},
+		{"list", "extra"},
+		{"config", "set", "server"},
+	}
+
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	t.Cleanup(func() { rootCmd.SetArgs(nil) })
+	for _, args := range tests {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			rootCmd.SetArgs(args)
+			err := rootCmd.ExecuteContext(context.Background())
+			if err == nil {
+				t.Fatal("Execute() should fail")
+			}
+			if code := output.Classify(err).ExitCode; code != output.ExitUsage {
+				t.Errorf("Execute() = %v, exit code %d, want %d", err, code, output.ExitUsage)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// Package status provides the command for showing a recorded secret from the local history.
+package status
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/brentdalling/ots-cli/internal/history"
+	"github.com/brentdalling/ots-cli/internal/link"
+)
+
+// StatusCmd is the cobra command for showing one recorded secret.
+var StatusCmd = &cobra.Command{
+	Use:   "status <link-or-id>",
+	Short: "Show a secret recorded in the local history",
+	Long: "Show what the local history knows about a secret: where it lives, when it expires and its read limit.\n" +
+		"The server is not contacted, since fetching a secret to check it would consume a read.",
+	Args: cobra.ExactArgs(1),
+	RunE: runStatus,
+}
+
+// runStatus handles the status command execution.
+func runStatus(cmd *cobra.Command, args []string) error {
+	l, err := link.ParseLinkOrID(args[0])
+	if err != nil {
+		return err
+	}
+
+	store, err := history.Default()
+	if err != nil {
+		return err
+	}
+
+	entries, err := store.Prune()
+	if err != nil {
+		return err
+	}
+
+	entry, ok := history.Find(entries, l.ID)
+	if !ok {
+		return fmt.Errorf("%s is not in the local history (it was not recorded, or expired more than %s ago)", l.ID, history.Retention)
+	}
+
+	now := time.Now()
+	fmt.Printf("ID:       %s\n", entry.ID)
+	if entry.Label != "" {
+		fmt.Printf("Label:    %s\n", entry.Label)
+	}
+	fmt.Printf("Server:   %s\n", entry.Server)
+	fmt.Printf("Created:  %s\n", entry.CreatedAt.Local().Format(time.RFC1123))
+	if entry.ExpiresAt != nil {
+		fmt.Printf("Expires:  %s (%s)\n", entry.ExpiresAt.Local().Format(time.RFC1123), relative(*entry.ExpiresAt, now))
+	} else {
+		fmt.Println("Expires:  never")
+	}
+	fmt.Printf("Reads:    limit %d\n", entry.MaxReads)
+	if len(entry.Parts) > 0 {
+		fmt.Printf("Parts:    %d (%s)\n", len(entry.Parts), strings.Join(entry.Parts, ", "))
+	}
+	fmt.Printf("State:    %s\n", entry.State(now))
+	return nil
+}
+
+// relative describes t as a duration from now, rounded to the minute.
+func relative(t, now time.Time) string {
+	d := t.Sub(now).Round(time.Minute)
+	if d < 0 {
+		return fmt.Sprintf("%s ago", -d)
+	}
+	return fmt.Sprintf("in %s", d)
+}
@@ -5,14 +5,13 @@ go 1.24.0
 toolchain go1.24.9
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/atotto/clipboard v0.1.4
 	github.com/spf13/cobra v1.10.1
+	github.com/spf13/pflag v1.0.10
 	golang.org/x/crypto v0.43.0
+	golang.org/x/sys v0.37.0
 	golang.org/x/term v0.36.0
 )
 
-require (
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/pflag v1.0.10 // indirect
-	golang.org/x/sys v0.37.0 // indirect
-)
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect
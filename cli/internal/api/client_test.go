@@ -0,0 +1,201 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// errorServer answers every request with status and body.
+func errorServer(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestClient returns a client that retries without sleeping.
+func newTestClient(baseURL string) *Client {
+	c := NewClient(baseURL)
+	c.sleep = func(time.Duration) {}
+	return c
+}
+
+func TestRetrieveSecret_StatusErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		sentinel error
+		message  string
+	}{
+		{"not found", http.StatusNotFound, `{"error":"Secret not found"}`, ErrNotFound, "Secret not found"},
+		{"rate limited", http.StatusTooManyRequests, `{"error":"Rate limit exceeded"}`, ErrRateLimited, "Rate limit exceeded"},
+		{"too large", http.StatusRequestEntityTooLarge, `{"error":"Request body is too large"}`, ErrPayloadTooLarge, "Request body is too large"},
+		{"bad gateway", http.StatusBadGateway, "<html>bad gateway</html>\n", ErrUnavailable, "<html>bad gateway</html>"},
+		{"unavailable", http.StatusServiceUnavailable, "", ErrUnavailable, ""},
+		{"server error", http.StatusInternalServerError, `{"error":"boom"}`, nil, "boom"},
+	}
+
+	sentinels := []error{ErrNotFound, ErrRateLimited, ErrPayloadTooLarge, ErrUnavailable}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(errorServer(t, tt.status, tt.body).URL)
+
+			_, err := client.RetrieveSecret("01ABC")
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error is %T (%v), want *APIError", err, err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Message != tt.message {
+				t.Errorf("APIError = %d %q, want %d %q", apiErr.StatusCode, apiErr.Message, tt.status, tt.message)
+			}
+			for _, s := range sentinels {
+				if got := errors.Is(err, s); got != (s == tt.sentinel) {
+					t.Errorf("errors.Is(err, %v) = %v", s, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRetrieveSecret_AccessPassword(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get(AccessPasswordHeader) {
+		case "":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "right":
+			w.Write([]byte(`{"ciphertext":"c","iv":"i","salt":"s","kdf":"pbkdf2","kdfParams":{}}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+	client := newTestClient(srv.URL)
+
+	if _, err := client.RetrieveSecret("01ABC"); !errors.Is(err, ErrAccessPasswordRequired) {
+		t.Errorf("without hash: got %v, want ErrAccessPasswordRequired", err)
+	}
+	if _, err := client.RetrieveSecretWithAccess("01ABC", "wrong"); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("wrong hash: got %v, want ErrAccessDenied", err)
+	}
+	if resp, err := client.RetrieveSecretWithAccess("01ABC", "right"); err != nil || resp.Ciphertext != "c" {
+		t.Errorf("right hash: got %v, %v", resp, err)
+	}
+}
+
+func TestDeleteSecret_NotFound(t *testing.T) {
+	client := newTestClient(errorServer(t, http.StatusNotFound, `{"error":"Secret not found"}`).URL)
+	if err := client.DeleteSecret("01ABC"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteSecret() = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSecretPath_Escaped(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(srv.URL)
+	client.RetrieveSecret("../admin?x=1")
+	client.DeleteSecret("a/b")
+	want := []string{"/api/v1/ots/..%2Fadmin%3Fx=1", "/api/v1/ots/a%2Fb"}
+	if strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Errorf("request paths = %q, want %q", paths, want)
+	}
+}
+
+func TestCreateSecret_TooLarge(t *testing.T) {
+	var called bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	_, err := newTestClient(srv.URL).CreateSecret(&CreateSecretRequest{Ciphertext: strings.Repeat("a", MaxCiphertextLength+1)})
+	if !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("CreateSecret() = %v, want ErrPayloadTooLarge", err)
+	}
+	if called {
+		t.Error("oversized secret should be rejected before it is sent")
+	}
+}
+
+func TestConnectionErrors(t *testing.T) {
+	t.Run("refused", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		url := srv.URL
+		srv.Close()
+
+		_, err := newTestClient(url).RetrieveSecret("01ABC")
+		assertConnectionError(t, err, ConnRefused)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer srv.Close()
+		defer close(release)
+
+		client := newTestClient(srv.URL)
+		client.Timeout = 50 * time.Millisecond
+		_, err := client.RetrieveSecret("01ABC")
+		assertConnectionError(t, err, ConnTimeout)
+	})
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+		srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+		srv.StartTLS()
+		defer srv.Close()
+
+		_, err := newTestClient(srv.URL).RetrieveSecret("01ABC")
+		assertConnectionError(t, err, ConnTLS)
+	})
+
+	t.Run("closed without response", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+		}))
+		defer srv.Close()
+
+		_, err := newTestClient(srv.URL).RetrieveSecret("01ABC")
+		assertConnectionError(t, err, ConnClosed)
+	})
+}
+
+func assertConnectionError(t *testing.T, err error, kind ConnectionKind) {
+	t.Helper()
+	var connErr *ConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("error is %T (%v), want *ConnectionError", err, err)
+	}
+	if connErr.Kind != kind {
+		t.Errorf("Kind = %d, want %d (%v)", connErr.Kind, kind, err)
+	}
+	if !errors.Is(err, ErrUnavailable) {
+		t.Error("connection errors should match ErrUnavailable")
+	}
+	if strings.Contains(err.Error(), "\n") {
+		t.Errorf("error message should be a single line without hints: %q", err.Error())
+	}
+}
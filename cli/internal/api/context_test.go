@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// hangingServer accepts requests and never answers them until the test ends.
+func hangingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+	return srv
+}
+
+func TestContext_CancelAbortsRequest(t *testing.T) {
+	client := newTestClient(hangingServer(t).URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := client.CreateSecretContext(ctx, &CreateSecretRequest{Ciphertext: "c"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("CreateSecretContext() = %v, want context.Canceled", err)
+	}
+	var connErr *ConnectionError
+	if errors.As(err, &connErr) {
+		t.Error("cancellation should not be reported as a connection error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("request took %v after cancellation", elapsed)
+	}
+}
+
+func TestContext_CanceledBeforeStart(t *testing.T) {
+	srv, count := flakyServer(t, 0, nil, retrieved)
+	client := newTestClient(srv.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.RetrieveSecretContext(ctx, "01ABC"); !errors.Is(err, context.Canceled) {
+		t.Errorf("RetrieveSecretContext() = %v, want context.Canceled", err)
+	}
+	if err := client.DeleteSecretContext(ctx, "01ABC"); !errors.Is(err, context.Canceled) {
+		t.Errorf("DeleteSecretContext() = %v, want context.Canceled", err)
+	}
+	if count.Load() != 0 {
+		t.Errorf("requests = %d, want none", count.Load())
+	}
+}
+
+func TestContext_CancelInterruptsRetryWait(t *testing.T) {
+	srv, count := flakyServer(t, 100, status(http.StatusServiceUnavailable, nil), deleted)
+	client, err := NewClientWithOptions(srv.URL, Options{Retry: &RetryPolicy{MaxRetries: 3, BaseDelay: time.Minute, MaxDelay: time.Hour}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	if err := client.DeleteSecretContext(ctx, "01ABC"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("DeleteSecretContext() = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("backoff was not interrupted: took %v", elapsed)
+	}
+	if count.Load() != 1 {
+		t.Errorf("requests = %d, want 1", count.Load())
+	}
+}
+
+// The per-attempt timeout fails an attempt; a context deadline ends the whole call.
+// Either way the call timed out, so it is reported as a timeout ConnectionError.
+func TestContext_DeadlineBoundsRetries(t *testing.T) {
+	t.Run("during a request", func(t *testing.T) {
+		client := newTestClient(hangingServer(t).URL)
+		client.Timeout = time.Hour
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := client.CreateSecretContext(ctx, &CreateSecretRequest{Ciphertext: "c"})
+		assertConnectionError(t, err, ConnTimeout)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("CreateSecretContext() = %v, want context.DeadlineExceeded", err)
+		}
+	})
+
+	t.Run("during a backoff", func(t *testing.T) {
+		srv, _ := flakyServer(t, 100, status(http.StatusServiceUnavailable, nil), deleted)
+		client, err := NewClientWithOptions(srv.URL, Options{Retry: &RetryPolicy{MaxRetries: 3, BaseDelay: time.Minute, MaxDelay: time.Hour}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err = client.DeleteSecretContext(ctx, "01ABC")
+		assertConnectionError(t, err, ConnTimeout)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("DeleteSecretContext() = %v, want context.DeadlineExceeded", err)
+		}
+	})
+}
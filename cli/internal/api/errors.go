@@ -0,0 +1,182 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the secret does not exist, has expired, or was already consumed.
+	ErrNotFound = errors.New("secret not found")
+	// ErrAccessPasswordRequired is returned when the secret has an access password and none was sent.
+	ErrAccessPasswordRequired = errors.New("access password required")
+	// ErrAccessDenied is returned when the access password does not match.
+	ErrAccessDenied = errors.New("wrong access password")
+	// ErrRateLimited is returned when the server answers 429 Too Many Requests.
+	ErrRateLimited = errors.New("rate limited by server")
+	// ErrPayloadTooLarge is returned when a secret exceeds the server's size limits,
+	// whether the client caught it before sending or the server answered 413.
+	ErrPayloadTooLarge = errors.New("secret too large")
+	// ErrUnavailable is returned when the server cannot be reached or answers 502, 503 or 504.
+	ErrUnavailable = errors.New("server unavailable")
+)
+
+// ErrorResponse represents an error response from the API.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// APIError is an error response from the server.
+// Depending on the status it matches ErrNotFound, ErrRateLimited, ErrPayloadTooLarge
+// or ErrUnavailable with errors.Is.
+type APIError struct {
+	StatusCode int
+	// Message is the server's error message, or the raw body if it sent none
+	Message string
+	// RetryAfter is how long the server asked clients to wait, for 429 responses
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error (%d): %s", e.StatusCode, e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrPayloadTooLarge:
+		return e.StatusCode == http.StatusRequestEntityTooLarge
+	case ErrUnavailable:
+		return e.StatusCode == http.StatusBadGateway ||
+			e.StatusCode == http.StatusServiceUnavailable ||
+			e.StatusCode == http.StatusGatewayTimeout
+	}
+	return false
+}
+
+// ConnectionKind classifies why the server could not be reached.
+type ConnectionKind int
+
+const (
+	// ConnOther is any connection failure not covered below
+	ConnOther ConnectionKind = iota
+	// ConnRefused means nothing is listening at the server address
+	ConnRefused
+	// ConnDial means the address could not be reached, for example an unknown host
+	ConnDial
+	// ConnTimeout means the server did not respond in time
+	ConnTimeout
+	// ConnTLS means the TLS handshake or certificate verification failed
+	ConnTLS
+	// ConnClosed means the server closed the connection without a response
+	ConnClosed
+	// ConnProxy means the proxy could not be reached or refused to connect to the server
+	ConnProxy
+)
+
+// ConnectionError is a failure to reach the server. It matches ErrUnavailable with errors.Is.
+type ConnectionError struct {
+	// URL is the request URL
+	URL  string
+	Kind ConnectionKind
+	Err  error
+}
+
+func (e *ConnectionError) Error() string {
+	switch e.Kind {
+	case ConnRefused:
+		return fmt.Sprintf("cannot connect to server at %s (connection refused)", e.URL)
+	case ConnDial:
+		return fmt.Sprintf("cannot connect to server at %s: %v", e.URL, unwrapURLError(e.Err))
+	case ConnTimeout:
+		return fmt.Sprintf("connection timeout to %s", e.URL)
+	case ConnTLS:
+		return fmt.Sprintf("TLS error connecting to %s: %v", e.URL, unwrapURLError(e.Err))
+	case ConnClosed:
+		return fmt.Sprintf("connection to %s closed without a response (EOF)", e.URL)
+	case ConnProxy:
+		return fmt.Sprintf("cannot connect to %s through the proxy: %v", e.URL, unwrapURLError(e.Err))
+	}
+	return fmt.Sprintf("connection error to %s: %v", e.URL, unwrapURLError(e.Err))
+}
+
+func (e *ConnectionError) Unwrap() error { return e.Err }
+
+func (e *ConnectionError) Is(target error) bool { return target == ErrUnavailable }
+
+// parseErrorResponse parses an error response from the API.
+func parseErrorResponse(resp *response) error {
+	apiErr := &APIError{StatusCode: resp.status, Message: strings.TrimSpace(string(resp.body))}
+
+	var errResp ErrorResponse
+	if err := json.Unmarshal(resp.body, &errResp); err == nil && errResp.Error != "" {
+		apiErr.Message = errResp.Error
+	}
+	if resp.status == http.StatusTooManyRequests {
+		apiErr.RetryAfter = rateLimitWait(resp.header)
+	}
+	return apiErr
+}
+
+// newConnectionError classifies an error returned by http.Client.Do.
+func newConnectionError(err error, url string) error {
+	if err == nil {
+		return nil
+	}
+	return &ConnectionError{URL: url, Kind: connectionKind(err), Err: err}
+}
+
+// connectionKind inspects the error chain rather than the message text.
+func connectionKind(err error) ConnectionKind {
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &opErr) && opErr.Op == "proxyconnect", errors.Is(err, errProxyRefused):
+		return ConnProxy
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ConnRefused
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ConnTimeout
+	case isTLSError(err):
+		return ConnTLS
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET):
+		return ConnClosed
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return ConnDial
+	}
+	return ConnOther
+}
+
+// isTLSError reports whether err comes from the TLS handshake, certificate verification or pinning.
+func isTLSError(err error) bool {
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.Is(err, ErrPinMismatch) || errors.As(err, &certErr) || errors.As(err, &recordErr) || errors.As(err, &alertErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
+
+// unwrapURLError strips the "Get "url": " prefix net/http adds, since ConnectionError names the URL itself.
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
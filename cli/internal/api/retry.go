@@ -0,0 +1,242 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt; zero disables retries
+	MaxRetries int
+	// BaseDelay is the delay before the first retry; it doubles on each further retry
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delay and any wait requested by the server.
+	// A 429 asking for a longer wait is returned instead of retried.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is the retry policy used by NewClient.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   30 * time.Second,
+}
+
+// retrySafety says which failures a request can safely be sent again after.
+type retrySafety int
+
+const (
+	// retryIdempotent requests can be repeated after any transient failure:
+	// connection errors other than TLS, 429 and 5xx. Only deleting a secret is idempotent.
+	retryIdempotent retrySafety = iota
+	// retryUnsent requests are repeated only when the server provably never processed them:
+	// the connection could not be established, or the rate limiter answered 429, which it
+	// does before the route handler runs. Retrieving a secret consumes a read as soon as the
+	// handler runs, so a retry after a timeout or a 5xx could burn the last read; creating one
+	// stores it, so such a retry could leave a live copy whose ID was never returned.
+	retryUnsent
+)
+
+// response is a complete HTTP response.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// do sends a request built by newRequest, retrying transient failures allowed by safety.
+// It returns the final response, whatever its status, or the final connection error.
+// If ctx ends first, including while waiting between attempts, its error is returned as
+// contextError reports it.
+func (c *Client) do(ctx context.Context, newRequest func(ctx context.Context) (*http.Request, error), safety retrySafety) (*response, error) {
+	for retry := 0; ; retry++ {
+		if err := c.waitForRateLimit(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.send(ctx, newRequest)
+		if resp != nil {
+			c.trackRateLimit(resp)
+		}
+
+		if retry >= c.Retry.MaxRetries || !retryable(resp, err, safety) {
+			return resp, err
+		}
+
+		delay := c.Retry.backoff(retry)
+		if resp != nil && resp.status == http.StatusTooManyRequests {
+			if wait := rateLimitWait(resp.header); wait > 0 {
+				delay = wait
+			}
+		}
+		if delay > c.Retry.MaxDelay {
+			return resp, err
+		}
+		if err := c.wait(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// send performs a single attempt, bounded by c.Timeout.
+func (c *Client) send(ctx context.Context, newRequest func(ctx context.Context) (*http.Request, error)) (*response, error) {
+	attemptCtx := ctx
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	req, err := newRequest(attemptCtx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkTransport(req.URL); err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, c.attemptError(ctx, err, req)
+	}
+	defer resp.Body.Close()
+
+	body, err := readBody(resp)
+	if err != nil {
+		return nil, c.attemptError(ctx, err, req)
+	}
+	return &response{status: resp.StatusCode, header: resp.Header, body: body}, nil
+}
+
+// attemptError reports a failed attempt: the caller's context error if ctx ended,
+// otherwise a ConnectionError, which covers the attempt's own timeout.
+func (c *Client) attemptError(ctx context.Context, err error, req *http.Request) error {
+	if ctx.Err() != nil {
+		return contextError(ctx, c.displayURL(req.URL))
+	}
+	// A redirect to plain HTTP was refused before anything was sent; the server is reachable
+	if errors.Is(err, ErrInsecureHTTP) {
+		return err
+	}
+	return newConnectionError(err, c.displayURL(req.URL))
+}
+
+// contextError returns the error of the ended ctx. A passed deadline is a timeout like any
+// other, so it is returned as a ConnTimeout ConnectionError that still matches
+// context.DeadlineExceeded; a cancellation is returned as is.
+func contextError(ctx context.Context, url string) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &ConnectionError{URL: url, Kind: ConnTimeout, Err: err}
+	}
+	return err
+}
+
+// retryable reports whether a failed attempt may be repeated.
+func retryable(resp *response, err error, safety retrySafety) bool {
+	if err != nil {
+		var connErr *ConnectionError
+		if !errors.As(err, &connErr) || connErr.Kind == ConnTLS || errors.Is(err, errProxyRefused) {
+			return false
+		}
+		return safety == retryIdempotent || neverSent(err)
+	}
+
+	switch resp.status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return safety == retryIdempotent
+	}
+	return false
+}
+
+// neverSent reports whether err happened while connecting, before any of the request was written.
+func neverSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// backoff returns the delay before the given retry (0-based): exponential, capped at MaxDelay,
+// with jitter so clients that failed together do not retry together.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.BaseDelay << retry
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + rand.N(d/2)
+}
+
+// rateLimitWait returns how long the server asked clients to wait, from Retry-After
+// (seconds or an HTTP date) or else X-RateLimit-Reset (seconds), or zero if neither is set.
+func rateLimitWait(header http.Header) time.Duration {
+	if s := header.Get("Retry-After"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			return time.Duration(n) * time.Second
+		}
+		if t, err := http.ParseTime(s); err == nil {
+			return max(time.Until(t), 0)
+		}
+	}
+	if n, err := strconv.Atoi(header.Get("X-RateLimit-Reset")); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return 0
+}
+
+// trackRateLimit remembers when the server says the request budget is used up,
+// so the next request waits for the window to reset instead of being rejected.
+func (c *Client) trackRateLimit(resp *response) {
+	if resp.header.Get("X-RateLimit-Remaining") != "0" || resp.status == http.StatusTooManyRequests {
+		return
+	}
+	if wait := rateLimitWait(resp.header); wait > 0 {
+		c.mu.Lock()
+		c.notBefore = time.Now().Add(wait)
+		c.mu.Unlock()
+	}
+}
+
+// waitForRateLimit sleeps until a rate limit window recorded by trackRateLimit has reset,
+// for at most MaxDelay. The reset time is kept until it passes, so every request sent
+// before then waits, not only the first.
+func (c *Client) waitForRateLimit(ctx context.Context) error {
+	c.mu.Lock()
+	wait := time.Until(c.notBefore)
+	c.mu.Unlock()
+
+	if wait > 0 {
+		return c.wait(ctx, min(wait, c.Retry.MaxDelay))
+	}
+	return nil
+}
+
+// wait pauses before the next attempt, returning early with ctx's error if it ends.
+func (c *Client) wait(ctx context.Context, d time.Duration) error {
+	if c.sleep != nil {
+		c.sleep(d)
+		if ctx.Err() != nil {
+			return contextError(ctx, c.BaseURL)
+		}
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return contextError(ctx, c.BaseURL)
+	}
+}
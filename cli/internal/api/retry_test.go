@@ -0,0 +1,368 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first failures requests with fail, then answers with succeed.
+// It returns the server and a counter of requests received.
+func flakyServer(t *testing.T, failures int32, fail, succeed http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var count atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count.Add(1) <= failures {
+			fail(w, r)
+			return
+		}
+		succeed(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &count
+}
+
+func status(code int, header map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(code)
+		w.Write([]byte(`{"error":"failed"}`))
+	}
+}
+
+func created(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(`{"id":"01ABC","remainingReads":1}`))
+}
+
+func retrieved(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(`{"ciphertext":"c","iv":"i","salt":"s","kdf":"pbkdf2","kdfParams":{}}`))
+}
+
+func deleted(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(`{"message":"deleted"}`))
+}
+
+func hangUp(w http.ResponseWriter, r *http.Request) {
+	if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+		conn.Close()
+	}
+}
+
+// recordSleeps makes c record its waits instead of sleeping.
+func recordSleeps(c *Client) *[]time.Duration {
+	var sleeps []time.Duration
+	c.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	return &sleeps
+}
+
+func TestDeleteSecret_RetriesTransientFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		fail http.HandlerFunc
+	}{
+		{"503", status(http.StatusServiceUnavailable, nil)},
+		{"500", status(http.StatusInternalServerError, nil)},
+		{"429", status(http.StatusTooManyRequests, nil)},
+		{"connection closed", hangUp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, count := flakyServer(t, 2, tt.fail, deleted)
+			client := NewClient(srv.URL)
+			sleeps := recordSleeps(client)
+
+			if err := client.DeleteSecret("01ABC"); err != nil {
+				t.Fatalf("DeleteSecret() failed: %v", err)
+			}
+			if count.Load() != 3 || len(*sleeps) != 2 {
+				t.Errorf("requests = %d, sleeps = %d, want 3 and 2", count.Load(), len(*sleeps))
+			}
+		})
+	}
+}
+
+func TestDeleteSecret_GivesUpAfterMaxRetries(t *testing.T) {
+	srv, count := flakyServer(t, 100, status(http.StatusBadGateway, nil), deleted)
+	client := NewClient(srv.URL)
+	recordSleeps(client)
+
+	err := client.DeleteSecret("01ABC")
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("DeleteSecret() = %v, want ErrUnavailable", err)
+	}
+	if want := int32(DefaultRetryPolicy.MaxRetries + 1); count.Load() != want {
+		t.Errorf("requests = %d, want %d", count.Load(), want)
+	}
+}
+
+// A create that reached the handler may have stored the secret, so repeating it could leave
+// a live copy whose ID was never returned.
+func TestCreateSecret_NeverRetriesAfterServerSawRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		fail http.HandlerFunc
+	}{
+		{"500", status(http.StatusInternalServerError, nil)},
+		{"502", status(http.StatusBadGateway, nil)},
+		{"503", status(http.StatusServiceUnavailable, nil)},
+		{"504", status(http.StatusGatewayTimeout, nil)},
+		{"connection closed", hangUp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, count := flakyServer(t, 1, tt.fail, created)
+			client := NewClient(srv.URL)
+			recordSleeps(client)
+
+			if _, err := client.CreateSecret(&CreateSecretRequest{Ciphertext: "c"}); err == nil {
+				t.Fatal("CreateSecret() should return the first failure")
+			}
+			if count.Load() != 1 {
+				t.Errorf("requests = %d, want 1", count.Load())
+			}
+		})
+	}
+}
+
+func TestCreateSecret_RetriesUnsentRequests(t *testing.T) {
+	t.Run("rate limited", func(t *testing.T) {
+		srv, count := flakyServer(t, 2, status(http.StatusTooManyRequests, nil), created)
+		client := NewClient(srv.URL)
+		sleeps := recordSleeps(client)
+
+		resp, err := client.CreateSecret(&CreateSecretRequest{Ciphertext: "c"})
+		if err != nil {
+			t.Fatalf("CreateSecret() failed: %v", err)
+		}
+		if resp.ID != "01ABC" {
+			t.Errorf("ID = %q", resp.ID)
+		}
+		if count.Load() != 3 || len(*sleeps) != 2 {
+			t.Errorf("requests = %d, sleeps = %d, want 3 and 2", count.Load(), len(*sleeps))
+		}
+	})
+
+	t.Run("connection refused", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		url := srv.URL
+		srv.Close()
+
+		client := NewClient(url)
+		sleeps := recordSleeps(client)
+
+		_, err := client.CreateSecret(&CreateSecretRequest{Ciphertext: "c"})
+		assertConnectionError(t, err, ConnRefused)
+		if len(*sleeps) != DefaultRetryPolicy.MaxRetries {
+			t.Errorf("sleeps = %d, want %d", len(*sleeps), DefaultRetryPolicy.MaxRetries)
+		}
+	})
+}
+
+func TestCreateSecret_DoesNotRetryClientErrors(t *testing.T) {
+	srv, count := flakyServer(t, 100, status(http.StatusBadRequest, nil), created)
+	client := NewClient(srv.URL)
+	recordSleeps(client)
+
+	if _, err := client.CreateSecret(&CreateSecretRequest{Ciphertext: "c"}); err == nil {
+		t.Fatal("CreateSecret() should fail")
+	}
+	if count.Load() != 1 {
+		t.Errorf("requests = %d, want 1", count.Load())
+	}
+}
+
+// A retrieval that reached the handler may have consumed the last read, so it must not be repeated.
+func TestRetrieveSecret_NeverRetriesAfterServerSawRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		fail http.HandlerFunc
+	}{
+		{"500", status(http.StatusInternalServerError, nil)},
+		{"502", status(http.StatusBadGateway, nil)},
+		{"503", status(http.StatusServiceUnavailable, nil)},
+		{"504", status(http.StatusGatewayTimeout, nil)},
+		{"connection closed", hangUp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, count := flakyServer(t, 1, tt.fail, retrieved)
+			client := NewClient(srv.URL)
+			recordSleeps(client)
+
+			if _, err := client.RetrieveSecret("01ABC"); err == nil {
+				t.Fatal("RetrieveSecret() should return the first failure")
+			}
+			if count.Load() != 1 {
+				t.Errorf("requests = %d, want 1", count.Load())
+			}
+		})
+	}
+}
+
+func TestRetrieveSecret_RetriesUnsentRequests(t *testing.T) {
+	t.Run("rate limited", func(t *testing.T) {
+		srv, count := flakyServer(t, 1, status(http.StatusTooManyRequests, map[string]string{"Retry-After": "2"}), retrieved)
+		client := NewClient(srv.URL)
+		sleeps := recordSleeps(client)
+
+		if _, err := client.RetrieveSecret("01ABC"); err != nil {
+			t.Fatalf("RetrieveSecret() failed: %v", err)
+		}
+		if count.Load() != 2 {
+			t.Errorf("requests = %d, want 2", count.Load())
+		}
+		if len(*sleeps) != 1 || (*sleeps)[0] != 2*time.Second {
+			t.Errorf("sleeps = %v, want [2s] from Retry-After", *sleeps)
+		}
+	})
+
+	t.Run("connection refused", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		url := srv.URL
+		srv.Close()
+
+		client := NewClient(url)
+		sleeps := recordSleeps(client)
+
+		_, err := client.RetrieveSecret("01ABC")
+		assertConnectionError(t, err, ConnRefused)
+		if len(*sleeps) != DefaultRetryPolicy.MaxRetries {
+			t.Errorf("sleeps = %d, want %d", len(*sleeps), DefaultRetryPolicy.MaxRetries)
+		}
+	})
+}
+
+func TestRateLimit_LongWaitIsReturned(t *testing.T) {
+	srv, count := flakyServer(t, 1, status(http.StatusTooManyRequests, map[string]string{"Retry-After": "3600"}), retrieved)
+	client := NewClient(srv.URL)
+	sleeps := recordSleeps(client)
+
+	_, err := client.RetrieveSecret("01ABC")
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("RetrieveSecret() = %v, want a rate limit APIError", err)
+	}
+	if apiErr.RetryAfter != time.Hour {
+		t.Errorf("RetryAfter = %v, want 1h", apiErr.RetryAfter)
+	}
+	if count.Load() != 1 || len(*sleeps) != 0 {
+		t.Errorf("requests = %d, sleeps = %v; a wait beyond MaxDelay should not be retried", count.Load(), *sleeps)
+	}
+}
+
+func TestRateLimit_WaitsForExhaustedWindow(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "20")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "5")
+		created(w, r)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	sleeps := recordSleeps(client)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.CreateSecret(&CreateSecretRequest{Ciphertext: "c"}); err != nil {
+			t.Fatalf("CreateSecret() failed: %v", err)
+		}
+	}
+
+	if len(*sleeps) != 1 || (*sleeps)[0] <= 4*time.Second || (*sleeps)[0] > 5*time.Second {
+		t.Errorf("sleeps = %v, want one wait of about 5s before the second request", *sleeps)
+	}
+}
+
+// Every request sent before an exhausted window resets waits for it, not only the next one.
+func TestRateLimit_WindowIsKeptUntilReset(t *testing.T) {
+	var count atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count.Add(1) == 1 {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", "5")
+		}
+		retrieved(w, r)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	sleeps := recordSleeps(client)
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.RetrieveSecret("01ABC"); err != nil {
+			t.Fatalf("RetrieveSecret() failed: %v", err)
+		}
+	}
+
+	if len(*sleeps) != 2 {
+		t.Errorf("sleeps = %v, want a wait before each request after the first", *sleeps)
+	}
+}
+
+func TestRetry_Disabled(t *testing.T) {
+	srv, count := flakyServer(t, 1, status(http.StatusServiceUnavailable, nil), deleted)
+	client, err := NewClientWithOptions(srv.URL, Options{Retry: &RetryPolicy{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.DeleteSecret("01ABC"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("DeleteSecret() = %v, want ErrUnavailable", err)
+	}
+	if count.Load() != 1 {
+		t.Errorf("requests = %d, want 1", count.Load())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for retry, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for i := 0; i < 20; i++ {
+			d := p.backoff(retry)
+			if d < want/2 || d > want {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", retry, d, want/2, want)
+			}
+		}
+	}
+
+	if d := p.backoff(100); d < p.MaxDelay/2 || d > p.MaxDelay {
+		t.Errorf("backoff(100) = %v, should be capped at MaxDelay despite overflow", d)
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   time.Duration
+	}{
+		{"none", nil, 0},
+		{"retry-after seconds", map[string]string{"Retry-After": "7"}, 7 * time.Second},
+		{"reset seconds", map[string]string{"X-RateLimit-Reset": "12"}, 12 * time.Second},
+		{"retry-after wins", map[string]string{"Retry-After": "3", "X-RateLimit-Reset": "12"}, 3 * time.Second},
+		{"past date", map[string]string{"Retry-After": "Mon, 02 Jan 2006 15:04:05 GMT"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.header {
+				h.Set(k, v)
+			}
+			if got := rateLimitWait(h); got != tt.want {
+				t.Errorf("rateLimitWait() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrInsecureHTTP is returned for plain HTTP requests to a host other than this machine,
+	// unless Options.AllowInsecureHTTP is set.
+	ErrInsecureHTTP = errors.New("refusing to send secrets over plain HTTP")
+	// ErrPinMismatch is returned when no certificate presented by the server matches a pinned public key.
+	ErrPinMismatch = errors.New("server public key does not match the pinned key")
+)
+
+// pinPrefix starts each pin, as in curl's --pinnedpubkey.
+const pinPrefix = "sha256//"
+
+// ParsePins parses SPKI pins of the form sha256//<base64 SHA-256 of the public key>,
+// separated by semicolons so that a backup key can be pinned alongside the current one.
+func ParsePins(s string) ([][]byte, error) {
+	var pins [][]byte
+	for _, field := range strings.Split(s, ";") {
+		field = strings.TrimSpace(field)
+		encoded, ok := strings.CutPrefix(field, pinPrefix)
+		if !ok {
+			return nil, fmt.Errorf("invalid pin %q: expected %s<base64 SHA-256 of the public key>", field, pinPrefix)
+		}
+		pin, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(pin) != sha256.Size {
+			return nil, fmt.Errorf("invalid pin %q: not a base64 SHA-256 digest", field)
+		}
+		pins = append(pins, pin)
+	}
+	return pins, nil
+}
+
+// PinFor returns the pin of a certificate's public key, in the form ParsePins accepts.
+func PinFor(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return pinPrefix + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// tlsConfig builds the TLS settings for opts, or returns nil if the defaults will do.
+func tlsConfig(opts Options) (*tls.Config, error) {
+	if opts.CAFile == "" && opts.ClientCertFile == "" && opts.ClientKeyFile == "" && opts.Pin == "" {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.CAFile != "" {
+		pool, err := loadCertPool(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	if opts.ClientCertFile != "" || opts.ClientKeyFile != "" {
+		if opts.ClientCertFile == "" {
+			return nil, fmt.Errorf("a client key needs a client certificate")
+		}
+		// The key may be in the same PEM file as the certificate
+		keyFile := opts.ClientKeyFile
+		if keyFile == "" {
+			keyFile = opts.ClientCertFile
+		}
+		cert, err := tls.LoadX509KeyPair(opts.ClientCertFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if opts.Pin != "" {
+		pins, err := ParsePins(opts.Pin)
+		if err != nil {
+			return nil, err
+		}
+		cfg.VerifyConnection = verifyPins(pins)
+	}
+	return cfg, nil
+}
+
+// loadCertPool returns the system roots plus the certificates in the PEM file at path.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CA file: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("CA file %s contains no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+// verifyPins accepts a connection if any certificate in a verified chain has a pinned public key.
+// Pinning is on top of normal verification, never instead of it.
+func verifyPins(pins [][]byte) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		for _, chain := range cs.VerifiedChains {
+			for _, cert := range chain {
+				sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				for _, pin := range pins {
+					if bytes.Equal(sum[:], pin) {
+						return nil
+					}
+				}
+			}
+		}
+		return ErrPinMismatch
+	}
+}
+
+// checkTransport refuses plain HTTP to other hosts: the ciphertext, the access password hash
+// and the secret ID would cross the network unprotected. The key never leaves the client, but
+// the ID is enough for an eavesdropper to consume or delete the secret.
+func (c *Client) checkTransport(u *url.URL) error {
+	if c.AllowInsecureHTTP || !strings.EqualFold(u.Scheme, "http") || isLoopback(u.Hostname()) {
+		return nil
+	}
+	return fmt.Errorf("%w to %s", ErrInsecureHTTP, u.Host)
+}
+
+// isLoopback reports whether host always refers to this machine.
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// checkRedirect stops redirects that would downgrade a request to plain HTTP.
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return c.checkTransport(req.URL)
+}
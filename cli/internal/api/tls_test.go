@@ -0,0 +1,234 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tlsServer starts an HTTPS server answering every request with a created secret.
+// configure, if non-nil, adjusts the server's TLS settings before it starts.
+func tlsServer(t *testing.T, configure func(*tls.Config)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(created))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.TLS = &tls.Config{}
+	if configure != nil {
+		configure(srv.TLS)
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// writePEM writes PEM blocks to a file in a temporary directory and returns its path.
+func writePEM(t *testing.T, name string, blocks ...*pem.Block) string {
+	t.Helper()
+	var b strings.Builder
+	for _, block := range blocks {
+		b.Write(pem.EncodeToMemory(block))
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// serverCAFile writes the test server's certificate as a CA file.
+func serverCAFile(t *testing.T, srv *httptest.Server) string {
+	return writePEM(t, "ca.pem", &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+}
+
+// clientCertificate creates a self-signed client certificate and returns it with its certificate and key blocks.
+func clientCertificate(t *testing.T) (*x509.Certificate, *pem.Block, *pem.Block) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ots test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, &pem.Block{Type: "CERTIFICATE", Bytes: der}, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}
+}
+
+func create(t *testing.T, url string, opts Options) error {
+	t.Helper()
+	opts.Retry = &RetryPolicy{}
+	client, err := NewClientWithOptions(url, opts)
+	if err != nil {
+		t.Fatalf("NewClientWithOptions() failed: %v", err)
+	}
+	_, err = client.CreateSecret(&CreateSecretRequest{Ciphertext: "c"})
+	return err
+}
+
+func TestCAFile(t *testing.T) {
+	srv := tlsServer(t, nil)
+
+	assertConnectionError(t, create(t, srv.URL, Options{}), ConnTLS)
+	if err := create(t, srv.URL, Options{CAFile: serverCAFile(t, srv)}); err != nil {
+		t.Errorf("with the server's CA: %v", err)
+	}
+}
+
+func TestPin(t *testing.T) {
+	srv := tlsServer(t, nil)
+	caFile := serverCAFile(t, srv)
+	pin := PinFor(srv.Certificate())
+	other, _, _ := clientCertificate(t)
+
+	if err := create(t, srv.URL, Options{CAFile: caFile, Pin: pin}); err != nil {
+		t.Errorf("matching pin: %v", err)
+	}
+	if err := create(t, srv.URL, Options{CAFile: caFile, Pin: PinFor(other) + ";" + pin}); err != nil {
+		t.Errorf("backup pin listed first: %v", err)
+	}
+
+	err := create(t, srv.URL, Options{CAFile: caFile, Pin: PinFor(other)})
+	assertConnectionError(t, err, ConnTLS)
+	if !errors.Is(err, ErrPinMismatch) {
+		t.Errorf("wrong pin: %v, want ErrPinMismatch", err)
+	}
+
+	// A pin does not replace certificate verification
+	assertConnectionError(t, create(t, srv.URL, Options{Pin: pin}), ConnTLS)
+}
+
+func TestClientCertificate(t *testing.T) {
+	cert, certPEM, keyPEM := clientCertificate(t)
+	srv := tlsServer(t, func(cfg *tls.Config) {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = x509.NewCertPool()
+		cfg.ClientCAs.AddCert(cert)
+	})
+	caFile := serverCAFile(t, srv)
+
+	if err := create(t, srv.URL, Options{CAFile: caFile}); err == nil {
+		t.Error("request without a client certificate should fail")
+	}
+
+	certFile := writePEM(t, "client.pem", certPEM)
+	keyFile := writePEM(t, "client.key", keyPEM)
+	if err := create(t, srv.URL, Options{CAFile: caFile, ClientCertFile: certFile, ClientKeyFile: keyFile}); err != nil {
+		t.Errorf("separate certificate and key: %v", err)
+	}
+
+	combined := writePEM(t, "combined.pem", certPEM, keyPEM)
+	if err := create(t, srv.URL, Options{CAFile: caFile, ClientCertFile: combined}); err != nil {
+		t.Errorf("certificate and key in one file: %v", err)
+	}
+
+	if _, err := NewClientWithOptions(srv.URL, Options{ClientKeyFile: keyFile}); err == nil {
+		t.Error("a key without a certificate should be rejected")
+	}
+}
+
+func TestParsePins(t *testing.T) {
+	valid := "sha256//" + strings.Repeat("A", 43) + "="
+	tests := []struct {
+		pin     string
+		count   int
+		wantErr bool
+	}{
+		{valid, 1, false},
+		{valid + "; " + valid, 2, false},
+		{strings.TrimPrefix(valid, "sha256//"), 0, true},
+		{"sha256//not base64!", 0, true},
+		{"sha256//AAAA", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		pins, err := ParsePins(tt.pin)
+		if (err != nil) != tt.wantErr || len(pins) != tt.count {
+			t.Errorf("ParsePins(%q) = %d pins, %v", tt.pin, len(pins), err)
+		}
+	}
+}
+
+func TestInsecureHTTP(t *testing.T) {
+	client := NewClient("http://ots.example.com")
+	if _, err := client.RetrieveSecret("01ABC"); !errors.Is(err, ErrInsecureHTTP) {
+		t.Errorf("plain HTTP to a remote host: %v, want ErrInsecureHTTP", err)
+	}
+	if err := client.DeleteSecret("01ABC"); !errors.Is(err, ErrInsecureHTTP) {
+		t.Errorf("plain HTTP to a remote host: %v, want ErrInsecureHTTP", err)
+	}
+
+	client.AllowInsecureHTTP = true
+	client.Retry = RetryPolicy{}
+	if _, err := client.RetrieveSecret("01ABC"); errors.Is(err, ErrInsecureHTTP) {
+		t.Error("AllowInsecureHTTP should permit plain HTTP")
+	}
+}
+
+func TestInsecureHTTP_RedirectDowngrade(t *testing.T) {
+	srv := tlsServer(t, nil)
+	srv.Config.Handler = http.RedirectHandler("http://ots.example.com/api/v1/ots/01ABC", http.StatusFound)
+
+	client, err := NewClientWithOptions(srv.URL, Options{CAFile: serverCAFile(t, srv)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.RetrieveSecret("01ABC")
+	var connErr *ConnectionError
+	if !errors.Is(err, ErrInsecureHTTP) || errors.As(err, &connErr) {
+		t.Errorf("redirect to plain HTTP: %v, want ErrInsecureHTTP", err)
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	tests := map[string]bool{
+		"localhost":     true,
+		"LOCALHOST":     true,
+		"ots.localhost": true,
+		"127.0.0.1":     true,
+		"127.1.2.3":     true,
+		"::1":           true,
+		"10.0.0.1":      false,
+		"example.com":   false,
+		"localhost.com": false,
+	}
+	for host, want := range tests {
+		if got := isLoopback(host); got != want {
+			t.Errorf("isLoopback(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Package apitest provides an in-memory OTS API server for tests.
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/brentdalling/ots-cli/internal/api"
+)
+
+// ExpiresAt is the expiry, in Unix milliseconds, reported for every created secret.
+const ExpiresAt = 1700000000000
+
+// Server is an in-memory stand-in for the OTS API. It honors each secret's read limit
+// and access password hash, and serves any number of clients at once.
+type Server struct {
+	// FailAfter, if set, makes every create after that many fail with 400
+	FailAfter int
+
+	mu      sync.Mutex
+	secrets map[string]*secret
+	next    int
+}
+
+// secret is a stored secret and the reads it has left.
+type secret struct {
+	req       api.CreateSecretRequest
+	remaining int
+}
+
+// New returns an empty server, to be served by an http.Server or httptest.
+func New() *Server {
+	return &Server{secrets: make(map[string]*secret)}
+}
+
+// Start serves a new server over HTTP until the test ends and returns it with its URL.
+func Start(t testing.TB) (*Server, string) {
+	s := New()
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv.URL
+}
+
+// Stored returns the create request of a secret that can still be read.
+func (s *Server) Stored(id string) (api.CreateSecretRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec, ok := s.secrets[id]
+	if !ok {
+		return api.CreateSecretRequest{}, false
+	}
+	return sec.req, true
+}
+
+// Len returns how many secrets can still be read.
+func (s *Server) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.secrets)
+}
+
+// Remove drops a secret, as if someone else had read it.
+func (s *Server) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.secrets, id)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/v1/ots/")
+	if r.Method == http.MethodPost {
+		s.create(w, r)
+		return
+	}
+	sec, ok := s.secrets[id]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"Secret not found"}`))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if hash := sec.req.AccessPasswordHash; hash != "" {
+			switch r.Header.Get("X-Access-Password-Hash") {
+			case "":
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			case hash:
+			default:
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+		if sec.remaining--; sec.remaining == 0 {
+			delete(s.secrets, id)
+		}
+		json.NewEncoder(w).Encode(api.RetrieveSecretResponse{
+			Ciphertext: sec.req.Ciphertext, IV: sec.req.IV, Salt: sec.req.Salt, KDF: sec.req.KDF, KDFParams: sec.req.KDFParams,
+		})
+	case http.MethodDelete:
+		delete(s.secrets, id)
+		w.Write([]byte(`{"success":true}`))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// create stores a secret with the read limit it asks for, one by default.
+func (s *Server) create(w http.ResponseWriter, r *http.Request) {
+	var req api.CreateSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (s.FailAfter > 0 && s.next >= s.FailAfter) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	reads := 1
+	if req.MaxReads != nil && (req.BurnAfterRead == nil || !*req.BurnAfterRead) {
+		reads = *req.MaxReads
+	}
+
+	s.next++
+	id := fmt.Sprintf("secret%03d", s.next)
+	s.secrets[id] = &secret{req: req, remaining: reads}
+	expiresAt := int64(ExpiresAt)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(api.CreateSecretResponse{ID: id, ExpiresAt: &expiresAt, RemainingReads: reads})
+}
@@ -0,0 +1,317 @@
+// Package bundle packs files and directories into a self-describing payload
+// so they can be shared as a one-time secret and restored with their original name and mode.
+//
+// Format: magic || uint32 big-endian header length || JSON header || gzip(data)
+// where data is the raw file contents, or a tar archive for directories.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// TypeFile is a single regular file
+	TypeFile = "file"
+	// TypeDir is a directory packed as a tar archive
+	TypeDir = "dir"
+	// MaxUnpackedSize bounds the decompressed size to protect against decompression bombs
+	MaxUnpackedSize = 64 << 20
+	// maxHeaderSize bounds the JSON header length
+	maxHeaderSize = 4096
+)
+
+// magic identifies a bundle. The leading NUL byte keeps it from being mistaken for a text secret.
+var magic = []byte("\x00OTSB1")
+
+var (
+	// ErrNotBundle is returned when data does not start with the bundle magic
+	ErrNotBundle = errors.New("not a file bundle")
+	// ErrTooLarge is returned when a bundle decompresses to more than MaxUnpackedSize
+	ErrTooLarge = errors.New("bundle exceeds maximum unpacked size")
+	// ErrUnsafePath is returned for archive entries that would escape the destination directory
+	ErrUnsafePath = errors.New("unsafe path in bundle")
+)
+
+// Header describes the packed content. It travels inside the encrypted payload,
+// so the server never learns the filename.
+type Header struct {
+	Type string      `json:"type"`
+	Name string      `json:"name"`
+	Mode fs.FileMode `json:"mode"`
+}
+
+// Bundle is an unpacked bundle.
+type Bundle struct {
+	Header
+	// Data is the file contents, or a tar archive of the directory
+	Data []byte
+}
+
+// IsBundle reports whether data starts with the bundle magic.
+func IsBundle(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Pack reads the file or directory at path and packs it with its base name and permissions.
+// Symlinks and special files inside a directory are skipped.
+func Pack(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := Header{Name: filepath.Base(path), Mode: info.Mode().Perm()}
+	var data []byte
+	switch {
+	case info.Mode().IsRegular():
+		header.Type = TypeFile
+		data, err = os.ReadFile(path)
+	case info.IsDir():
+		header.Type = TypeDir
+		data, err = tarDir(path)
+	default:
+		return nil, fmt.Errorf("%s is not a regular file or directory", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return encode(header, data)
+}
+
+// Unpack parses and decompresses a bundle.
+func Unpack(data []byte) (*Bundle, error) {
+	if !IsBundle(data) {
+		return nil, ErrNotBundle
+	}
+	rest := data[len(magic):]
+	if len(rest) < 4 {
+		return nil, fmt.Errorf("truncated bundle header")
+	}
+
+	headerLen := binary.BigEndian.Uint32(rest)
+	rest = rest[4:]
+	if headerLen > maxHeaderSize || int(headerLen) > len(rest) {
+		return nil, fmt.Errorf("invalid bundle header length")
+	}
+
+	var b Bundle
+	if err := json.Unmarshal(rest[:headerLen], &b.Header); err != nil {
+		return nil, fmt.Errorf("decode bundle header: %w", err)
+	}
+	if b.Type != TypeFile && b.Type != TypeDir {
+		return nil, fmt.Errorf("unknown bundle type %q", b.Type)
+	}
+	b.Name = filepath.Base(filepath.Clean("/" + b.Name))
+	if b.Name == "/" || b.Name == "." {
+		b.Name = "secret"
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(rest[headerLen:]))
+	if err != nil {
+		return nil, fmt.Errorf("decompress bundle: %w", err)
+	}
+	defer zr.Close()
+
+	b.Data, err = io.ReadAll(io.LimitReader(zr, MaxUnpackedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("decompress bundle: %w", err)
+	}
+	if len(b.Data) > MaxUnpackedSize {
+		return nil, ErrTooLarge
+	}
+
+	return &b, nil
+}
+
+// Extract writes the bundle to dest and returns the path written.
+// If dest is an existing directory, the bundle is written inside it under its original name,
+// with a numeric suffix if that name is taken.
+// Existing files are never overwritten, and group and other permission bits are dropped.
+func (b *Bundle) Extract(dest string) (string, error) {
+	target := dest
+	if info, err := os.Stat(dest); err == nil && info.IsDir() {
+		target = UniquePath(filepath.Join(dest, b.Name))
+	}
+
+	if b.Type == TypeFile {
+		return target, writeFile(target, bytes.NewReader(b.Data), b.Mode)
+	}
+
+	if err := os.Mkdir(target, SafeMode(b.Mode|0o700)); err != nil {
+		return "", err
+	}
+	return target, untar(target, b.Data)
+}
+
+// SafeMode strips group, other and special bits, keeping the file readable by its owner.
+func SafeMode(mode fs.FileMode) fs.FileMode {
+	return mode.Perm()&0o700 | 0o400
+}
+
+// UniquePath returns path, or path with a " (N)" suffix before the extension if path already exists.
+func UniquePath(path string) string {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	base := path[:len(path)-len(ext)]
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// WriteFile writes data to a new file with owner-only permissions, refusing to overwrite.
+func WriteFile(path string, data []byte) error {
+	return writeFile(path, bytes.NewReader(data), 0o600)
+}
+
+// encode serializes the header and compresses the data.
+func encode(header Header, data []byte) ([]byte, error) {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(magic)
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(headerJSON)))
+	buf.Write(length[:])
+	buf.Write(headerJSON)
+
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// tarDir archives the regular files and directories under root with root-relative names.
+func tarDir(root string) ([]byte, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		hdr.Uname, hdr.Gname = "", ""
+		hdr.Uid, hdr.Gid = 0, 0
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// untar extracts regular files and directories into root, rejecting paths that escape it.
+func untar(root string, data []byte) error {
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read archive: %w", err)
+		}
+
+		name := filepath.FromSlash(hdr.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("%w: %s", ErrUnsafePath, hdr.Name)
+		}
+		path := filepath.Join(root, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0o700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+				return err
+			}
+			if err := writeFile(path, tr, hdr.FileInfo().Mode()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// writeFile creates path exclusively with a safe mode and copies r into it.
+func writeFile(path string, r io.Reader, mode fs.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, SafeMode(mode))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Describe returns a short human-readable description of the bundle.
+func (b *Bundle) Describe() string {
+	kind := "file"
+	if b.Type == TypeDir {
+		kind = "directory"
+	}
+	return fmt.Sprintf("%s %q (%s)", kind, b.Name, b.Mode.Perm())
+}
@@ -0,0 +1,187 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPack_FileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "service.keytab")
+	data := []byte{0x05, 0x02, 0x00, 0xff, 0xfe, 0x00, 0x80}
+	if err := os.WriteFile(src, data, 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	packed, err := Pack(src)
+	if err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	if !IsBundle(packed) {
+		t.Fatal("Packed data should be recognized as a bundle")
+	}
+	if bytes.Contains(packed, data) {
+		t.Error("Packed data should be compressed, not stored raw")
+	}
+
+	b, err := Unpack(packed)
+	if err != nil {
+		t.Fatalf("Unpack failed: %v", err)
+	}
+	if b.Type != TypeFile || b.Name != "service.keytab" || b.Mode != 0o640 {
+		t.Errorf("Unexpected header: %+v", b.Header)
+	}
+	if !bytes.Equal(b.Data, data) {
+		t.Error("File contents should round-trip unchanged")
+	}
+
+	// Extracting into a directory uses the original name and drops group/other bits
+	outDir := t.TempDir()
+	written, err := b.Extract(outDir)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if written != filepath.Join(outDir, "service.keytab") {
+		t.Errorf("Unexpected path: %s", written)
+	}
+	info, err := os.Stat(written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected mode 0600, got %o", info.Mode().Perm())
+	}
+
+	// Never overwrite an existing file
+	if _, err := b.Extract(written); !errors.Is(err, os.ErrExist) {
+		t.Errorf("Expected os.ErrExist, got: %v", err)
+	}
+
+	// Extracting into the same directory again picks a fresh name
+	again, err := b.Extract(outDir)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if again != filepath.Join(outDir, "service (1).keytab") {
+		t.Errorf("Unexpected path: %s", again)
+	}
+}
+
+func TestPack_DirectoryRoundTrip(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "certs")
+	if err := os.MkdirAll(filepath.Join(src, "private"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"ca.pem":          "CA",
+		"private/key.pem": "KEY",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("/etc/passwd", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	packed, err := Pack(src)
+	if err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+
+	b, err := Unpack(packed)
+	if err != nil {
+		t.Fatalf("Unpack failed: %v", err)
+	}
+	if b.Type != TypeDir || b.Name != "certs" {
+		t.Errorf("Unexpected header: %+v", b.Header)
+	}
+
+	dest := filepath.Join(t.TempDir(), "restored")
+	written, err := b.Extract(dest)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if written != dest {
+		t.Errorf("Expected %s, got %s", dest, written)
+	}
+
+	for name, content := range files {
+		got, err := os.ReadFile(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if string(got) != content {
+			t.Errorf("%s: expected %q, got %q", name, content, got)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dest, "link")); !os.IsNotExist(err) {
+		t.Error("Symlinks should not be packed")
+	}
+}
+
+func TestExtract_RejectsPathTraversal(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	payload := []byte("owned")
+	if err := tw.WriteHeader(&tar.Header{Name: "../escape", Mode: 0o644, Size: int64(len(payload)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(payload)
+	tw.Close()
+
+	packed, err := encode(Header{Type: TypeDir, Name: "evil", Mode: 0o755}, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Unpack(packed)
+	if err != nil {
+		t.Fatalf("Unpack failed: %v", err)
+	}
+
+	parent := t.TempDir()
+	if _, err := b.Extract(filepath.Join(parent, "out")); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("Expected ErrUnsafePath, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "escape")); !os.IsNotExist(err) {
+		t.Error("Entry escaped the destination directory")
+	}
+}
+
+func TestUnpack_SanitizesName(t *testing.T) {
+	packed, err := encode(Header{Type: TypeFile, Name: "../../etc/cron.d/x", Mode: 0o644}, []byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Unpack(packed)
+	if err != nil {
+		t.Fatalf("Unpack failed: %v", err)
+	}
+	if b.Name != "x" {
+		t.Errorf("Expected sanitized name %q, got %q", "x", b.Name)
+	}
+}
+
+func TestUnpack_Invalid(t *testing.T) {
+	if _, err := Unpack([]byte("plain text secret")); !errors.Is(err, ErrNotBundle) {
+		t.Errorf("Expected ErrNotBundle, got: %v", err)
+	}
+	if _, err := Unpack(append(append([]byte{}, magic...), 0, 0)); err == nil {
+		t.Error("Expected error for truncated header")
+	}
+}
+
+func TestUnpack_TooLarge(t *testing.T) {
+	packed, err := encode(Header{Type: TypeFile, Name: "bomb", Mode: 0o644}, make([]byte, MaxUnpackedSize+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Unpack(packed); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge, got: %v", err)
+	}
+}
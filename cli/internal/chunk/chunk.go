@@ -0,0 +1,269 @@
+// Package chunk splits payloads that exceed the server's size limits into several
+// independently encrypted secrets, tied together by a manifest secret.
+//
+// Each chunk is encrypted with its own random key and stored as a normal secret.
+// The manifest lists the chunk IDs, their keys and digests, plus a digest of the
+// whole payload, and is itself shared as the one link the recipient receives.
+package chunk
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/brentdalling/ots-cli/internal/api"
+	"github.com/brentdalling/ots-cli/internal/crypto"
+	"github.com/brentdalling/ots-cli/internal/link"
+)
+
+const (
+	// Size is the plaintext size of each chunk. It leaves room for base64 expansion,
+	// the AEAD tag and the JSON request around the ciphertext within api.MaxBodyBytes.
+	Size = 45 * 1024
+	// MaxChunks bounds the number of chunks so the manifest itself, after password
+	// and outer encryption, still fits in one secret
+	MaxChunks = 128
+	// manifestVersion is the manifest format version
+	manifestVersion = 1
+	// requestOverhead is the room left in api.MaxBodyBytes for the JSON fields around the ciphertext
+	requestOverhead = 2048
+)
+
+// magic identifies a manifest. The leading NUL byte keeps it from being mistaken for a text secret.
+var magic = []byte("\x00OTSM1")
+
+var (
+	// ErrTooManyChunks is returned when a payload would need more than MaxChunks chunks
+	ErrTooManyChunks = errors.New("payload too large to split into chunks")
+	// ErrDigestMismatch is returned when a chunk or the reassembled payload fails verification
+	ErrDigestMismatch = errors.New("digest mismatch")
+)
+
+// Manifest lists the chunks of a split payload.
+type Manifest struct {
+	Version int     `json:"v"`
+	Size    int     `json:"size"`
+	SHA256  string  `json:"sha256"`
+	Chunks  []Entry `json:"chunks"`
+}
+
+// Entry identifies one stored chunk and the key needed to decrypt it.
+type Entry struct {
+	ID     string `json:"id"`
+	Key    string `json:"key"`
+	SHA256 string `json:"sha256"`
+}
+
+// PartialError reports a multi-part transfer that stopped part-way.
+// Consumed lists chunks whose secrets were read (download), or were stored and could not be
+// deleted again (upload), and Pending lists chunks that were never attempted.
+type PartialError struct {
+	Op       string
+	Index    int
+	Total    int
+	ID       string
+	Consumed []string
+	Pending  []string
+	Err      error
+}
+
+func (e *PartialError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s chunk %d/%d", e.Op, e.Index+1, e.Total)
+	if e.ID != "" {
+		fmt.Fprintf(&b, " (%s)", e.ID)
+	}
+	fmt.Fprintf(&b, " failed: %v", e.Err)
+	if len(e.Consumed) > 0 {
+		verb := "consumed"
+		if e.Op == "upload" {
+			verb = "stored and could not be deleted"
+		}
+		fmt.Fprintf(&b, "\n  %s: %s", verb, strings.Join(e.Consumed, ", "))
+	}
+	if len(e.Pending) > 0 {
+		fmt.Fprintf(&b, "\n  not attempted: %s", strings.Join(e.Pending, ", "))
+	}
+	return b.String()
+}
+
+func (e *PartialError) Unwrap() error { return e.Err }
+
+// Fits reports whether a ciphertext can be stored as a single secret.
+func Fits(ciphertext string) bool {
+	return len(ciphertext) <= api.MaxCiphertextLength && len(ciphertext) <= api.MaxBodyBytes-requestOverhead
+}
+
+// Split cuts data into pieces of at most size bytes.
+func Split(data []byte, size int) [][]byte {
+	var pieces [][]byte
+	for len(data) > size {
+		pieces = append(pieces, data[:size])
+		data = data[size:]
+	}
+	return append(pieces, data)
+}
+
+// Upload encrypts data in chunks and stores each one as its own secret.
+// Expiry and read limits are copied from template. Chunks are never password-protected:
+// their keys live only in the manifest, which carries the password layer if there is one.
+// progress, if non-nil, is called before each chunk is stored.
+// If a chunk fails, the chunks already stored are deleted before the *PartialError is returned.
+func Upload(ctx context.Context, client *api.Client, data []byte, template api.CreateSecretRequest, opts crypto.Options, progress func(index, total int)) (*Manifest, error) {
+	pieces := Split(data, Size)
+	if len(pieces) > MaxChunks {
+		return nil, fmt.Errorf("%w: %d bytes needs %d chunks, at most %d are allowed", ErrTooManyChunks, len(data), len(pieces), MaxChunks)
+	}
+
+	m := &Manifest{Version: manifestVersion, Size: len(data), SHA256: digest(data)}
+	for i, piece := range pieces {
+		if progress != nil {
+			progress(i, len(pieces))
+		}
+
+		encrypted, err := crypto.EncryptBytes(piece, "", opts)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt chunk %d: %w", i+1, err)
+		}
+
+		req := template
+		req.Ciphertext = encrypted.Ciphertext
+		req.IV = encrypted.IV
+		req.Salt = encrypted.Salt
+		req.KDF = encrypted.KDF.Name
+		req.KDFParams = encrypted.KDF.Map()
+		req.KDFParams["isPasswordProtected"] = false
+		req.KDFParams["version"] = encrypted.Version
+
+		resp, err := client.CreateSecretContext(ctx, &req)
+		if err != nil {
+			return nil, &PartialError{Op: "upload", Index: i, Total: len(pieces), Consumed: Delete(ctx, client, m.IDs()), Err: err}
+		}
+
+		m.Chunks = append(m.Chunks, Entry{ID: resp.ID, Key: encrypted.Key, SHA256: digest(piece)})
+	}
+
+	return m, nil
+}
+
+// Delete deletes stored chunks on a best-effort basis, once the payload they belong to
+// cannot be shared, and returns the IDs that could not be deleted. Chunks that are already
+// gone count as deleted. It runs even if ctx was canceled, since that is usually why the
+// upload stopped.
+func Delete(ctx context.Context, client *api.Client, ids []string) []string {
+	ctx = context.WithoutCancel(ctx)
+	var left []string
+	for _, id := range ids {
+		if err := client.DeleteSecretContext(ctx, id); err != nil && !errors.Is(err, api.ErrNotFound) {
+			left = append(left, id)
+		}
+	}
+	return left
+}
+
+// Download fetches, decrypts and verifies every chunk, then checks the overall digest.
+// progress, if non-nil, is called before each chunk is fetched.
+func Download(ctx context.Context, client *api.Client, m *Manifest, progress func(index, total int)) ([]byte, error) {
+	ids := m.IDs()
+	data := make([]byte, 0, m.Size)
+
+	for i, entry := range m.Chunks {
+		if progress != nil {
+			progress(i, len(m.Chunks))
+		}
+
+		fail := func(err error, consumed int) error {
+			return &PartialError{Op: "download", Index: i, Total: len(ids), ID: entry.ID, Consumed: ids[:consumed], Pending: ids[i+1:], Err: err}
+		}
+
+		resp, err := client.RetrieveSecretContext(ctx, entry.ID)
+		if err != nil {
+			return nil, fail(err, i)
+		}
+
+		kdf, err := crypto.ParseKDFParams(resp.KDF, resp.KDFParams)
+		if err != nil {
+			return nil, fail(err, i+1)
+		}
+
+		piece, err := crypto.DecryptBytes(&crypto.EncryptedSecret{
+			Ciphertext: resp.Ciphertext,
+			IV:         resp.IV,
+			Salt:       resp.Salt,
+			Key:        entry.Key,
+			Version:    crypto.VersionFromParams(resp.KDFParams),
+			KDF:        kdf,
+		}, "")
+		if err != nil {
+			return nil, fail(err, i+1)
+		}
+		if digest(piece) != entry.SHA256 {
+			return nil, fail(ErrDigestMismatch, i+1)
+		}
+
+		data = append(data, piece...)
+	}
+
+	if len(data) != m.Size || digest(data) != m.SHA256 {
+		return nil, fmt.Errorf("reassembled payload: %w", ErrDigestMismatch)
+	}
+	return data, nil
+}
+
+// IDs returns the chunk IDs in order.
+func (m *Manifest) IDs() []string {
+	ids := make([]string, len(m.Chunks))
+	for i, entry := range m.Chunks {
+		ids[i] = entry.ID
+	}
+	return ids
+}
+
+// Encode serializes the manifest so it can be shared as a secret.
+func (m *Manifest) Encode() ([]byte, error) {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, magic...), body...), nil
+}
+
+// IsManifest reports whether a decrypted secret is a chunk manifest.
+func IsManifest(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Decode parses a manifest produced by Encode.
+func Decode(data []byte) (*Manifest, error) {
+	if !IsManifest(data) {
+		return nil, fmt.Errorf("not a chunk manifest")
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data[len(magic):], &m); err != nil {
+		return nil, fmt.Errorf("decode manifest: %w", err)
+	}
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d", m.Version)
+	}
+	if len(m.Chunks) == 0 || len(m.Chunks) > MaxChunks || m.Size < 0 || m.Size > MaxChunks*Size {
+		return nil, fmt.Errorf("invalid manifest: %d chunks, %d bytes", len(m.Chunks), m.Size)
+	}
+	for _, entry := range m.Chunks {
+		if !link.ValidID(entry.ID) {
+			return nil, fmt.Errorf("invalid manifest: bad chunk ID %q", entry.ID)
+		}
+	}
+	return &m, nil
+}
+
+// digest returns the hex-encoded SHA-256 of data.
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
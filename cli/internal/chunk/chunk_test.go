@@ -0,0 +1,239 @@
+package chunk
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/brentdalling/ots-cli/internal/api"
+	"github.com/brentdalling/ots-cli/internal/apitest"
+	"github.com/brentdalling/ots-cli/internal/crypto"
+)
+
+// newFakeServer starts an in-memory OTS API and returns a client for it.
+func newFakeServer(t *testing.T) (*apitest.Server, *api.Client) {
+	f, url := apitest.Start(t)
+	return f, api.NewClient(url)
+}
+
+func randomData(t *testing.T, n int) []byte {
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestSplit(t *testing.T) {
+	pieces := Split([]byte("abcdefg"), 3)
+	if len(pieces) != 3 || string(pieces[0]) != "abc" || string(pieces[2]) != "g" {
+		t.Errorf("Unexpected pieces: %q", pieces)
+	}
+	if pieces := Split([]byte("abc"), 3); len(pieces) != 1 {
+		t.Errorf("Exact fit should be one piece, got %d", len(pieces))
+	}
+}
+
+func TestUploadDownload_RoundTrip(t *testing.T) {
+	f, client := newFakeServer(t)
+	data := randomData(t, 2*Size+123)
+
+	expires := "1h"
+	m, err := Upload(context.Background(), client, data, api.CreateSecretRequest{ExpiresIn: expires}, crypto.Options{}, nil)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if len(m.Chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d", len(m.Chunks))
+	}
+	for _, id := range m.IDs() {
+		req, _ := f.Stored(id)
+		if req.ExpiresIn != expires {
+			t.Errorf("%s: expiry should be copied from the template", id)
+		}
+		if len(req.Ciphertext) > api.MaxCiphertextLength {
+			t.Errorf("%s: ciphertext exceeds server limit", id)
+		}
+	}
+
+	// The manifest survives encoding and is recognized on the way back
+	encoded, err := m.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !IsManifest(encoded) {
+		t.Fatal("Encoded manifest should be recognized")
+	}
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	var calls int
+	got, err := Download(context.Background(), client, decoded, func(index, total int) { calls++ })
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Reassembled payload does not match")
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 progress calls, got %d", calls)
+	}
+}
+
+func TestDownload_ReportsConsumedChunks(t *testing.T) {
+	f, client := newFakeServer(t)
+	data := randomData(t, 3*Size)
+
+	m, err := Upload(context.Background(), client, data, api.CreateSecretRequest{}, crypto.Options{}, nil)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	// Someone else already read the second chunk
+	f.Remove(m.Chunks[1].ID)
+
+	_, err = Download(context.Background(), client, m, nil)
+	var partial *PartialError
+	if !errors.As(err, &partial) {
+		t.Fatalf("Expected *PartialError, got: %v", err)
+	}
+	if partial.Index != 1 || partial.ID != m.Chunks[1].ID {
+		t.Errorf("Expected failure at chunk 2, got %d (%s)", partial.Index+1, partial.ID)
+	}
+	if len(partial.Consumed) != 1 || partial.Consumed[0] != m.Chunks[0].ID {
+		t.Errorf("Expected first chunk consumed, got %v", partial.Consumed)
+	}
+	if len(partial.Pending) != 1 || partial.Pending[0] != m.Chunks[2].ID {
+		t.Errorf("Expected third chunk pending, got %v", partial.Pending)
+	}
+	if !strings.Contains(err.Error(), m.Chunks[0].ID) {
+		t.Errorf("Error should name the consumed chunk: %v", err)
+	}
+}
+
+func TestDownload_DetectsDigestMismatch(t *testing.T) {
+	_, client := newFakeServer(t)
+	data := randomData(t, Size+1)
+
+	m, err := Upload(context.Background(), client, data, api.CreateSecretRequest{}, crypto.Options{}, nil)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	m.Chunks[1].SHA256 = digest([]byte("something else"))
+
+	_, err = Download(context.Background(), client, m, nil)
+	if !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("Expected ErrDigestMismatch, got: %v", err)
+	}
+}
+
+func TestDownload_DetectsReorderedChunks(t *testing.T) {
+	_, client := newFakeServer(t)
+	data := randomData(t, 2*Size)
+
+	m, err := Upload(context.Background(), client, data, api.CreateSecretRequest{}, crypto.Options{}, nil)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	m.Chunks[0], m.Chunks[1] = m.Chunks[1], m.Chunks[0]
+
+	_, err = Download(context.Background(), client, m, nil)
+	if !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("Expected ErrDigestMismatch, got: %v", err)
+	}
+}
+
+func TestUpload_DeletesStoredChunksOnFailure(t *testing.T) {
+	f, client := newFakeServer(t)
+	f.FailAfter = 2
+
+	_, err := Upload(context.Background(), client, randomData(t, 3*Size), api.CreateSecretRequest{}, crypto.Options{}, nil)
+	var partial *PartialError
+	if !errors.As(err, &partial) {
+		t.Fatalf("Expected *PartialError, got: %v", err)
+	}
+	if partial.Index != 2 {
+		t.Errorf("Expected failure at chunk 3, got %d", partial.Index+1)
+	}
+	if len(partial.Consumed) != 0 {
+		t.Errorf("Expected no chunks left stored, got %v", partial.Consumed)
+	}
+	if n := f.Len(); n != 0 {
+		t.Errorf("Expected the stored chunks to be deleted, %d remain", n)
+	}
+}
+
+func TestDelete_ReportsChunksLeftStored(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/ots/gone":
+			w.WriteHeader(http.StatusNotFound)
+		case "/api/v1/ots/kept":
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	left := Delete(ctx, api.NewClient(srv.URL), []string{"deleted", "gone", "kept"})
+	if len(left) != 1 || left[0] != "kept" {
+		t.Errorf("Expected only kept to be left, got %v", left)
+	}
+}
+
+func TestUpload_TooManyChunks(t *testing.T) {
+	_, client := newFakeServer(t)
+	_, err := Upload(context.Background(), client, make([]byte, MaxChunks*Size+1), api.CreateSecretRequest{}, crypto.Options{}, nil)
+	if !errors.Is(err, ErrTooManyChunks) {
+		t.Errorf("Expected ErrTooManyChunks, got: %v", err)
+	}
+}
+
+func TestManifest_FitsInOneSecret(t *testing.T) {
+	m := &Manifest{Version: manifestVersion, Size: MaxChunks * Size, SHA256: digest(nil)}
+	for i := 0; i < MaxChunks; i++ {
+		m.Chunks = append(m.Chunks, Entry{ID: "01HZZZZZZZZZZZZZZZZZZZZZZZ", Key: strings.Repeat("a", 64), SHA256: digest(nil)})
+	}
+	encoded, err := m.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	kdf := crypto.KDFParams{Name: crypto.KDFScrypt, N: 1024, R: 8, P: 1}
+	encrypted, err := crypto.EncryptBytes(encoded, "password", crypto.Options{KDF: kdf})
+	if err != nil {
+		t.Fatalf("EncryptBytes failed: %v", err)
+	}
+	if len(encrypted.Ciphertext) > api.MaxBodyBytes-1024 {
+		t.Errorf("A full password-protected manifest is %d characters, too large for one secret", len(encrypted.Ciphertext))
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"no magic":      `{"v":1}`,
+		"bad version":   "\x00OTSM1" + `{"v":9,"chunks":[{"id":"a"}]}`,
+		"no chunks":     "\x00OTSM1" + `{"v":1,"chunks":[]}`,
+		"path in id":    "\x00OTSM1" + `{"v":1,"chunks":[{"id":"../admin"}]}`,
+		"dot-dot id":    "\x00OTSM1" + `{"v":1,"chunks":[{"id":".."}]}`,
+		"space in id":   "\x00OTSM1" + `{"v":1,"chunks":[{"id":"01A BC"}]}`,
+		"unicode id":    "\x00OTSM1" + `{"v":1,"chunks":[{"id":"01ÄBC"}]}`,
+		"negative size": "\x00OTSM1" + `{"v":1,"size":-1,"chunks":[{"id":"a"}]}`,
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Decode([]byte(input)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
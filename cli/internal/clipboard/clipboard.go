@@ -0,0 +1,149 @@
+// Package clipboard copies secrets to the system clipboard and clears them again after a while,
+// either from a detached helper process or in the foreground with a visible countdown.
+// The clipboard is only cleared while it still holds the secret, so anything copied since is kept.
+package clipboard
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	system "github.com/atotto/clipboard"
+)
+
+// HelperCommand is the hidden command that runs the detached clearer; see StartClearer.
+const HelperCommand = "clear-clipboard"
+
+// ErrUnavailable is returned when there is no clipboard, e.g. without xclip, xsel or wl-clipboard on Linux.
+var ErrUnavailable = errors.New("no clipboard available")
+
+// The system clipboard, replaced in tests.
+var (
+	readAll  = system.ReadAll
+	writeAll = system.WriteAll
+)
+
+// Available reports whether a clipboard can be used.
+func Available() bool {
+	return !system.Unsupported
+}
+
+// Copy puts text on the clipboard.
+func Copy(text string) error {
+	if !Available() {
+		return ErrUnavailable
+	}
+	return writeAll(text)
+}
+
+// Fingerprint identifies a secret without revealing it; ClearIf compares the clipboard to it.
+type Fingerprint [sha256.Size]byte
+
+// FingerprintOf returns the fingerprint of text.
+func FingerprintOf(text string) Fingerprint {
+	return sha256.Sum256([]byte(text))
+}
+
+// ClearIf empties the clipboard if it still holds the text with fingerprint fp, and reports whether it did.
+func ClearIf(fp Fingerprint) (bool, error) {
+	current, err := readAll()
+	if err != nil {
+		return false, fmt.Errorf("read clipboard: %w", err)
+	}
+	if FingerprintOf(current) != fp {
+		return false, nil
+	}
+	if err := writeAll(""); err != nil {
+		return false, fmt.Errorf("clear clipboard: %w", err)
+	}
+	return true, nil
+}
+
+// StartClearer starts a detached copy of this program that clears the clipboard after ttl
+// if it still holds text. The helper outlives this process and the terminal it runs in.
+// It learns only the fingerprint, through a pipe, so the secret never appears in its arguments or environment.
+func StartClearer(text string, ttl time.Duration) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("start clipboard clearer: %w", err)
+	}
+	fp := FingerprintOf(text)
+
+	cmd := exec.Command(exe, HelperCommand, ttl.String())
+	detach(cmd)
+	// Write the fingerprint ourselves: a reader would be copied by a goroutine that dies with this process
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("start clipboard clearer: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start clipboard clearer: %w", err)
+	}
+	_, err = io.WriteString(stdin, hex.EncodeToString(fp[:]))
+	if closeErr := stdin.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		return fmt.Errorf("start clipboard clearer: %w", err)
+	}
+	// The helper is not waited for; release its resources on our side
+	return cmd.Process.Release()
+}
+
+// RunClearer is the body of the helper started by StartClearer: it reads the fingerprint from r,
+// waits for ttl and clears the clipboard if it still holds the secret.
+func RunClearer(r io.Reader, ttl time.Duration) error {
+	encoded, err := io.ReadAll(io.LimitReader(r, 2*sha256.Size+1))
+	if err != nil {
+		return fmt.Errorf("read fingerprint: %w", err)
+	}
+	var fp Fingerprint
+	if n, err := hex.Decode(fp[:], bytes.TrimSpace(encoded)); err != nil || n != len(fp) {
+		return fmt.Errorf("invalid fingerprint")
+	}
+
+	time.Sleep(ttl)
+	_, err = ClearIf(fp)
+	return err
+}
+
+// Countdown shows the time left until the clipboard is cleared on w, then clears it if it still holds text.
+// Canceling ctx, e.g. with Ctrl-C, clears it at once. With tty false, the countdown is announced once
+// instead of being redrawn every second.
+func Countdown(ctx context.Context, w io.Writer, tty bool, text string, ttl time.Duration) (bool, error) {
+	fp := FingerprintOf(text)
+	deadline := time.Now().Add(ttl)
+
+	if !tty {
+		fmt.Fprintf(w, "Clearing the clipboard in %s (Ctrl-C to clear it now)\n", ttl)
+	}
+	timer := time.NewTimer(ttl)
+	defer timer.Stop()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+wait:
+	for {
+		if tty {
+			fmt.Fprintf(w, "\rClearing the clipboard in %s (Ctrl-C to clear it now) ", time.Until(deadline).Round(time.Second))
+		}
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			break wait
+		case <-ctx.Done():
+			break wait
+		}
+	}
+	if tty {
+		fmt.Fprintln(w)
+	}
+	return ClearIf(fp)
+}
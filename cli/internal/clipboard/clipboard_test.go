@@ -0,0 +1,101 @@
+package clipboard
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClipboard replaces the system clipboard for the duration of a test.
+func fakeClipboard(t *testing.T, content string) *string {
+	t.Helper()
+	oldRead, oldWrite := readAll, writeAll
+	t.Cleanup(func() { readAll, writeAll = oldRead, oldWrite })
+
+	readAll = func() (string, error) { return content, nil }
+	writeAll = func(text string) error {
+		content = text
+		return nil
+	}
+	return &content
+}
+
+func TestClearIf(t *testing.T) {
+	tests := []struct {
+		name      string
+		clipboard string
+		cleared   bool
+	}{
+		{"still the secret", "s3cret", true},
+		{"copied over", "something else", false},
+		{"already empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := fakeClipboard(t, tt.clipboard)
+			cleared, err := ClearIf(FingerprintOf("s3cret"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cleared != tt.cleared {
+				t.Errorf("ClearIf() = %v, want %v", cleared, tt.cleared)
+			}
+			want := tt.clipboard
+			if tt.cleared {
+				want = ""
+			}
+			if *content != want {
+				t.Errorf("clipboard = %q, want %q", *content, want)
+			}
+		})
+	}
+}
+
+func TestRunClearer(t *testing.T) {
+	content := fakeClipboard(t, "s3cret")
+	fp := FingerprintOf("s3cret")
+
+	if err := RunClearer(strings.NewReader(hex.EncodeToString(fp[:])+"\n"), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if *content != "" {
+		t.Errorf("clipboard = %q after the clearer ran", *content)
+	}
+
+	for _, input := range []string{"", "not hex", hex.EncodeToString(fp[:8])} {
+		if err := RunClearer(strings.NewReader(input), 0); err == nil {
+			t.Errorf("RunClearer(%q) should fail", input)
+		}
+	}
+}
+
+func TestCountdown(t *testing.T) {
+	content := fakeClipboard(t, "s3cret")
+	var out strings.Builder
+
+	cleared, err := Countdown(context.Background(), &out, false, "s3cret", 10*time.Millisecond)
+	if err != nil || !cleared || *content != "" {
+		t.Errorf("Countdown() = %v, %v with clipboard %q", cleared, err, *content)
+	}
+	if !strings.Contains(out.String(), "10ms") {
+		t.Errorf("countdown output %q should show the time left", out.String())
+	}
+}
+
+func TestCountdown_Canceled(t *testing.T) {
+	content := fakeClipboard(t, "s3cret")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	cleared, err := Countdown(ctx, &strings.Builder{}, true, "s3cret", time.Hour)
+	if err != nil || !cleared || *content != "" {
+		t.Errorf("Countdown() = %v, %v with clipboard %q", cleared, err, *content)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("a canceled countdown should clear at once")
+	}
+}
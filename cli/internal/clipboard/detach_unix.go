@@ -0,0 +1,13 @@
+//go:build !windows
+
+package clipboard
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach starts cmd in a new session, so closing the terminal does not stop it.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,14 @@
+package clipboard
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachedProcess starts a process without a console; see DETACHED_PROCESS in the Windows documentation.
+const detachedProcess = 0x00000008
+
+// detach starts cmd without a console in its own process group, so closing the console does not stop it.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess}
+}
@@ -1,36 +1,482 @@
 // Package config provides configuration management for the CLI.
+//
+// Settings are layered: built-in defaults, then the config file, then environment
+// variables. Commands apply their flags on top.
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brentdalling/ots-cli/internal/api"
+	"github.com/brentdalling/ots-cli/internal/crypto"
+)
+
+const (
+	// DefaultServerURL is used when no server is configured
+	DefaultServerURL = "http://localhost:3000"
+	// DefaultExpiresIn is the default secret lifetime
+	DefaultExpiresIn = "7d"
+	// DefaultTimeout is the default HTTP request timeout
+	DefaultTimeout = 30 * time.Second
+	// DefaultRetries is the default number of retries after a transient failure
+	DefaultRetries = 3
+	// MaxRetries is the largest retries setting accepted
+	MaxRetries = 10
+	// FileName is the config file name inside the config directory
+	FileName = "config.toml"
+)
+
+// Source says where a setting's effective value came from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceProfile Source = "profile"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
 )
 
+// timeoutOverride is the timeout passed with --timeout, if any.
+var timeoutOverride time.Duration
+
+// ErrUnknownKey is returned for config keys the CLI does not know.
+var ErrUnknownKey = errors.New("unknown config key")
+
+// expiresInPattern matches the durations the server accepts, e.g. 30m, 24h, 7d or PT24H
+var expiresInPattern = regexp.MustCompile(`^(?i:P?T?)?\d+[smhdSMHD]$`)
+
 // Config holds the CLI configuration.
 type Config struct {
 	ServerURL string
+	// ExpiresIn is the default lifetime for new secrets
+	ExpiresIn string
+	// BurnAfterRead makes new secrets readable only once by default
+	BurnAfterRead bool
+	// Clipboard copies links and redeemed secrets to the clipboard
+	Clipboard bool
+	// ClipboardTTL is how long a redeemed secret stays on the clipboard; zero keeps it
+	ClipboardTTL time.Duration
+	// KDF is the default password key derivation function
+	KDF string
+	// Timeout bounds each HTTP request attempt
+	Timeout time.Duration
+	// Retries is how many times a transient failure is retried
+	Retries int
+	// History enables the local ledger of created secrets
+	History bool
+	// Profile is the name of the active profile, if any
+	Profile string
+	// Profiles are the named server profiles from the config file
+	Profiles map[string]*Profile
+
+	// Path is the config file that was read
+	Path string
+
+	sources map[string]Source
 }
 
-// LoadConfig loads configuration from environment variables.
-// Defaults to http://localhost:3000 if OTS_SERVER_URL is not set.
-func LoadConfig() *Config {
-	cfg := &Config{
-		ServerURL: "http://localhost:3000", // Default
+// key describes one setting: its config file name, environment variable and how to validate it.
+type key struct {
+	name string
+	env  string
+	kind valueKind
+	help string
+	set  func(cfg *Config, s string) error
+	get  func(cfg *Config) string
+}
+
+// keys lists every setting in display order.
+var keys = []key{
+	{
+		name: "server", env: "OTS_SERVER_URL", kind: kindString,
+		help: "Default server URL",
+		set: func(cfg *Config, s string) error {
+			if err := ValidateServerURL(s); err != nil {
+				return err
+			}
+			cfg.ServerURL = s
+			return nil
+		},
+		get: func(cfg *Config) string { return cfg.ServerURL },
+	},
+	{
+		name: "expires-in", env: "OTS_EXPIRES_IN", kind: kindString,
+		help: "Default expiration for new secrets (e.g. 1h, 24h, 7d)",
+		set: func(cfg *Config, s string) error {
+			if err := ValidateExpiresIn(s); err != nil {
+				return err
+			}
+			cfg.ExpiresIn = s
+			return nil
+		},
+		get: func(cfg *Config) string { return cfg.ExpiresIn },
+	},
+	{
+		name: "burn-after-read", env: "OTS_BURN_AFTER_READ", kind: kindBool,
+		help: "Destroy new secrets after the first read",
+		set:  boolSetter(func(cfg *Config) *bool { return &cfg.BurnAfterRead }),
+		get:  func(cfg *Config) string { return strconv.FormatBool(cfg.BurnAfterRead) },
+	},
+	{
+		name: "clipboard", env: "OTS_CLIPBOARD", kind: kindBool,
+		help: "Copy links and redeemed secrets to the clipboard",
+		set:  boolSetter(func(cfg *Config) *bool { return &cfg.Clipboard }),
+		get:  func(cfg *Config) string { return strconv.FormatBool(cfg.Clipboard) },
+	},
+	{
+		name: "clipboard-ttl", env: "OTS_CLIPBOARD_TTL", kind: kindString,
+		help: "Clear a redeemed secret from the clipboard after this long (e.g. 30s; 0 keeps it)",
+		set: func(cfg *Config, s string) error {
+			d, err := time.ParseDuration(s)
+			if err != nil || d < 0 {
+				return fmt.Errorf("invalid clipboard-ttl %q (use a duration such as 30s, or 0 to keep the secret)", s)
+			}
+			cfg.ClipboardTTL = d
+			return nil
+		},
+		get: func(cfg *Config) string { return cfg.ClipboardTTL.String() },
+	},
+	{
+		name: "kdf", env: "OTS_KDF", kind: kindString,
+		help: "Password key derivation function (argon2id, scrypt, pbkdf2)",
+		set: func(cfg *Config, s string) error {
+			if _, err := crypto.DefaultKDFParams(s); err != nil {
+				return err
+			}
+			cfg.KDF = s
+			return nil
+		},
+		get: func(cfg *Config) string { return cfg.KDF },
+	},
+	{
+		name: "timeout", env: "OTS_TIMEOUT", kind: kindString,
+		help: "HTTP request timeout (e.g. 30s, 2m)",
+		set: func(cfg *Config, s string) error {
+			d, err := time.ParseDuration(s)
+			if err != nil || d <= 0 {
+				return fmt.Errorf("invalid timeout %q (use a positive duration such as 30s or 2m)", s)
+			}
+			cfg.Timeout = d
+			return nil
+		},
+		get: func(cfg *Config) string { return cfg.Timeout.String() },
+	},
+	{
+		name: "retries", env: "OTS_RETRIES", kind: kindInt,
+		help: "Retries after a transient failure such as a 503 or rate limit (0 disables)",
+		set: func(cfg *Config, s string) error {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 || n > MaxRetries {
+				return fmt.Errorf("invalid retries %q (use a number from 0 to %d)", s, MaxRetries)
+			}
+			cfg.Retries = n
+			return nil
+		},
+		get: func(cfg *Config) string { return strconv.Itoa(cfg.Retries) },
+	},
+	{
+		name: "history", env: "OTS_HISTORY", kind: kindBool,
+		help: "Record created secrets in the local history",
+		set:  boolSetter(func(cfg *Config) *bool { return &cfg.History }),
+		get:  func(cfg *Config) string { return strconv.FormatBool(cfg.History) },
+	},
+	{
+		name: "profile", env: "OTS_PROFILE", kind: kindString,
+		help: "Active server profile (overridden by --profile)",
+		set: func(cfg *Config, s string) error {
+			if !validKey(s) {
+				return fmt.Errorf("invalid profile name %q (use letters, digits, - and _)", s)
+			}
+			cfg.Profile = s
+			return nil
+		},
+		get: func(cfg *Config) string { return cfg.Profile },
+	},
+}
+
+// boolSetter returns a setter that parses a boolean into the field returned by field.
+func boolSetter(field func(cfg *Config) *bool) func(cfg *Config, s string) error {
+	return func(cfg *Config, s string) error {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q (use true or false)", s)
+		}
+		*field(cfg) = b
+		return nil
 	}
+}
 
-	// Check environment variable
-	if serverURL := os.Getenv("OTS_SERVER_URL"); serverURL != "" {
-		cfg.ServerURL = serverURL
+// lookupKey returns the setting with the given name.
+func lookupKey(name string) (key, error) {
+	for _, k := range keys {
+		if k.name == name {
+			return k, nil
+		}
 	}
+	return key{}, fmt.Errorf("%w %q (valid keys: %s)", ErrUnknownKey, name, keyNames())
+}
+
+// Keys returns the names of all settings in display order.
+func Keys() []string {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.name
+	}
+	return names
+}
+
+func keyNames() string {
+	names := Keys()
+	sort.Strings(names)
+	return fmt.Sprint(names)
+}
 
+// Default returns the built-in configuration.
+func Default() *Config {
+	cfg := &Config{
+		ServerURL: DefaultServerURL,
+		ExpiresIn: DefaultExpiresIn,
+		Clipboard: true,
+		KDF:       crypto.KDFArgon2id,
+		Timeout:   DefaultTimeout,
+		Retries:   DefaultRetries,
+		Profiles:  make(map[string]*Profile),
+		sources:   make(map[string]Source),
+	}
+	for _, k := range keys {
+		cfg.sources[k.name] = SourceDefault
+	}
 	return cfg
 }
 
-// GetConfigPath returns the path to the config file in the user's home directory.
+// OverrideTimeout makes d the request timeout for this process,
+// overriding OTS_TIMEOUT and the config file. Zero leaves the timeout alone.
+func OverrideTimeout(d time.Duration) {
+	timeoutOverride = d
+}
+
+// Load builds the configuration from the defaults, the config file at GetConfigPath and the environment,
+// then applies the active profile: the one passed to SelectProfile, else OTS_PROFILE, else the file's profile key.
+// A timeout passed to OverrideTimeout takes precedence over all of them.
+// Invalid values are reported as *Error naming the key and, for the file, the line.
+func Load() (*Config, error) {
+	cfg := Default()
+	cfg.Path = GetConfigPath()
+
+	if cfg.Path != "" {
+		doc, err := readDocument(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.applyDocument(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.applyProfile(); err != nil {
+		return nil, err
+	}
+	if timeoutOverride > 0 {
+		cfg.Timeout = timeoutOverride
+		cfg.sources["timeout"] = SourceFlag
+	}
+	return cfg, nil
+}
+
+// applyDocument applies the settings from a parsed config file.
+func (cfg *Config) applyDocument(doc *document) error {
+	for _, e := range doc.entries {
+		fail := func(err error) error {
+			return &Error{Source: doc.path, Line: e.line, Key: e.name(), Err: err}
+		}
+
+		if e.section != "" {
+			if err := cfg.applyProfileEntry(e); err != nil {
+				return fail(err)
+			}
+			continue
+		}
+
+		k, err := lookupKey(e.name())
+		if err != nil {
+			return fail(err)
+		}
+		if e.value.kind != k.kind {
+			return fail(fmt.Errorf("expected a %s, got a %s", k.kind, e.value.kind))
+		}
+		if err := k.set(cfg, e.value.s); err != nil {
+			return fail(err)
+		}
+		cfg.sources[k.name] = SourceFile
+	}
+
+	for section, line := range doc.sections {
+		// [profiles] only holds the profiles, as inline tables
+		if section == "profiles" {
+			continue
+		}
+		name, _, err := parseProfileSection(section)
+		if err != nil {
+			return &Error{Source: doc.path, Line: line, Key: section, Err: err}
+		}
+		if p := cfg.profile(name); p.ServerURL == "" {
+			return &Error{Source: doc.path, Line: line, Key: section, Err: fmt.Errorf("profile %q has no server", name)}
+		}
+	}
+	return nil
+}
+
+// applyEnv applies settings from OTS_* environment variables.
+func (cfg *Config) applyEnv() error {
+	for _, k := range keys {
+		s := os.Getenv(k.env)
+		if s == "" {
+			continue
+		}
+		if err := k.set(cfg, s); err != nil {
+			return &Error{Source: k.env, Err: err}
+		}
+		cfg.sources[k.name] = SourceEnv
+	}
+	return nil
+}
+
+// Get returns the effective value of a setting and where it came from.
+func (cfg *Config) Get(name string) (string, Source, error) {
+	k, err := lookupKey(name)
+	if err != nil {
+		return "", "", err
+	}
+	return k.get(cfg), cfg.sources[name], nil
+}
+
+// Set validates a setting and writes it to the config file at path.
+func Set(path, name, s string) error {
+	k, err := lookupKey(name)
+	if err != nil {
+		return err
+	}
+	if err := k.set(Default(), s); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	doc, err := readDocument(path)
+	if err != nil {
+		return err
+	}
+
+	v := value{kind: k.kind, s: s}
+	switch k.kind {
+	case kindBool:
+		b, _ := strconv.ParseBool(s)
+		v.s = strconv.FormatBool(b)
+	case kindInt:
+		n, _ := strconv.Atoi(s)
+		v.s = strconv.Itoa(n)
+	}
+	doc.set(name, v)
+	return doc.save()
+}
+
+// Unset removes a setting from the config file at path. It reports whether the setting was present.
+func Unset(path, name string) (bool, error) {
+	if _, err := lookupKey(name); err != nil {
+		return false, err
+	}
+
+	doc, err := readDocument(path)
+	if err != nil {
+		return false, err
+	}
+	if !doc.unset(name) {
+		return false, nil
+	}
+	return true, doc.save()
+}
+
+// Help returns a one-line description and the environment variable of a setting.
+func Help(name string) (description, env string) {
+	k, err := lookupKey(name)
+	if err != nil {
+		return "", ""
+	}
+	return k.help, k.env
+}
+
+// ValidateExpiresIn checks that s is a duration the server accepts.
+func ValidateExpiresIn(s string) error {
+	if !expiresInPattern.MatchString(s) {
+		return fmt.Errorf("invalid duration %q (use a number followed by s, m, h or d, e.g. 24h or 7d)", s)
+	}
+	return nil
+}
+
+// ValidateServerURL checks that s is an absolute http or https URL, or unix:// and a socket path.
+func ValidateServerURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", s, err)
+	}
+	if u.Scheme == api.UnixScheme {
+		if u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+			return fmt.Errorf("invalid URL %q (expected unix:///absolute/path/to/socket)", s)
+		}
+		return nil
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q (expected http://host, https://host or unix:///path/to/socket)", s)
+	}
+	return nil
+}
+
+// GetConfigPath returns the path of the config file.
+// OTS_CONFIG overrides it; otherwise it is config.toml in Dir.
+// The legacy ~/.otsconfig is used if it exists and the new file does not.
 func GetConfigPath() string {
-	homeDir, err := os.UserHomeDir()
+	if path := os.Getenv("OTS_CONFIG"); path != "" {
+		return path
+	}
+
+	dir, err := Dir()
 	if err != nil {
 		return ""
 	}
-	return filepath.Join(homeDir, ".otsconfig")
+	path := filepath.Join(dir, FileName)
+
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			legacy := filepath.Join(homeDir, ".otsconfig")
+			if _, err := os.Stat(legacy); err == nil {
+				return legacy
+			}
+		}
+	}
+	return path
+}
+
+// Dir returns the directory for the config file and CLI state such as the history ledger.
+// It is $XDG_CONFIG_HOME/ots when XDG_CONFIG_HOME is set, on every platform,
+// and otherwise the platform config directory, e.g. ~/.config/ots on Linux.
+func Dir() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, "ots"), nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "ots"), nil
 }
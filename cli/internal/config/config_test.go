@@ -0,0 +1,639 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brentdalling/ots-cli/internal/api"
+)
+
+// isolate points the config file and environment at a temporary directory.
+func isolate(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	t.Setenv("OTS_CONFIG", path)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	for _, k := range keys {
+		t.Setenv(k.env, "")
+	}
+	return path
+}
+
+func writeConfig(t *testing.T, path, text string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	isolate(t)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.ServerURL != DefaultServerURL || cfg.ExpiresIn != DefaultExpiresIn || !cfg.Clipboard || cfg.Timeout != DefaultTimeout {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+	if _, source, _ := cfg.Get("server"); source != SourceDefault {
+		t.Errorf("server source = %s, want default", source)
+	}
+}
+
+func TestLoad_Layering(t *testing.T) {
+	path := isolate(t)
+	writeConfig(t, path, `# team defaults
+server = "https://ots.example.com"   # production
+expires-in = '24h'
+burn-after-read = true
+clipboard = false
+clipboard-ttl = "30s"
+kdf = "scrypt"
+timeout = "10s"
+retries = 1
+`)
+	t.Setenv("OTS_KDF", "pbkdf2")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	if cfg.ServerURL != "https://ots.example.com" || cfg.ExpiresIn != "24h" || !cfg.BurnAfterRead || cfg.Clipboard || cfg.Timeout != 10*time.Second {
+		t.Errorf("file settings not applied: %+v", cfg)
+	}
+	if cfg.ClipboardTTL != 30*time.Second {
+		t.Errorf("ClipboardTTL = %v, want 30s", cfg.ClipboardTTL)
+	}
+	if cfg.KDF != "pbkdf2" {
+		t.Errorf("KDF = %s, want pbkdf2 from the environment", cfg.KDF)
+	}
+	if cfg.Retries != 1 || clientOptions(t, cfg, cfg.ServerURL).Retry.MaxRetries != 1 {
+		t.Errorf("Retries = %d, want 1 from the file, passed to the client", cfg.Retries)
+	}
+	if _, source, _ := cfg.Get("kdf"); source != SourceEnv {
+		t.Errorf("kdf source = %s, want env", source)
+	}
+	if _, source, _ := cfg.Get("server"); source != SourceFile {
+		t.Errorf("server source = %s, want file", source)
+	}
+}
+
+// Any TOML syntax is accepted, not only the forms ots writes itself.
+func TestLoad_TOMLSyntax(t *testing.T) {
+	path := isolate(t)
+	writeConfig(t, path, `server = """https://ots.example.com"""
+"expires-in" = "2h"
+retries = 0x2
+
+[profiles]
+lab = { server = "https://lab.example.com", headers = { "X-Team.Name" = 'ops' } }
+`)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.ServerURL != "https://ots.example.com" || cfg.ExpiresIn != "2h" || cfg.Retries != 2 {
+		t.Errorf("settings not applied: %+v", cfg)
+	}
+	if p := cfg.Profiles["lab"]; p == nil || p.ServerURL != "https://lab.example.com" || p.Headers["X-Team.Name"] != "ops" {
+		t.Errorf("inline profile not applied: %+v", p)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		line int
+		key  string
+	}{
+		{"unknown key", "server = \"http://a\"\nserverr = \"http://b\"\n", 2, "serverr"},
+		{"wrong type", "\n\nburn-after-read = \"yes\"\n", 3, "burn-after-read"},
+		{"invalid value", "kdf = \"bcrypt\"\n", 1, "kdf"},
+		{"bad url", "server = \"localhost:3000\"\n", 1, "server"},
+		{"bad expiry", "expires-in = \"a week\"\n", 1, "expires-in"},
+		{"bad timeout", "timeout = \"-1s\"\n", 1, "timeout"},
+		{"bad clipboard ttl", "clipboard-ttl = \"soon\"\n", 1, "clipboard-ttl"},
+		{"too many retries", "retries = 99\n", 1, "retries"},
+		{"quoted retries", "retries = \"3\"\n", 1, "retries"},
+		{"unquoted string", "# comment\nserver = http://a\n", 2, "server"},
+		{"unterminated string", "server = \"http://a\n", 1, "server"},
+		{"duplicate", "kdf = \"scrypt\"\nkdf = \"pbkdf2\"\n", 2, "kdf"},
+		{"missing equals", "server\n", 1, ""},
+		{"bad section", "[oops\n", 1, ""},
+		{"unknown section key", "[extra]\nfoo = 1\n", 2, "extra.foo"},
+		{"float", "\nretries = 1.5\n", 2, "retries"},
+		{"array", "kdf = [\"scrypt\"]\n", 1, "kdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := isolate(t)
+			writeConfig(t, path, tt.text)
+
+			_, err := Load()
+			var cfgErr *Error
+			if !errors.As(err, &cfgErr) {
+				t.Fatalf("Load() error = %v, want *Error", err)
+			}
+			if cfgErr.Line != tt.line || cfgErr.Key != tt.key || cfgErr.Source != path {
+				t.Errorf("error = %+v, want line %d key %q", cfgErr, tt.line, tt.key)
+			}
+			if tt.key != "" && !strings.Contains(err.Error(), tt.key) {
+				t.Errorf("error %q does not name key %q", err, tt.key)
+			}
+		})
+	}
+}
+
+func TestLoad_EnvError(t *testing.T) {
+	isolate(t)
+	t.Setenv("OTS_CLIPBOARD", "maybe")
+
+	_, err := Load()
+	var cfgErr *Error
+	if !errors.As(err, &cfgErr) || cfgErr.Source != "OTS_CLIPBOARD" {
+		t.Fatalf("Load() error = %v, want *Error from OTS_CLIPBOARD", err)
+	}
+}
+
+func TestSetUnset_PreservesFile(t *testing.T) {
+	path := isolate(t)
+	writeConfig(t, path, "# my settings\nkdf = \"scrypt\" # fast enough\n\n[future]\n")
+
+	// Load would reject the unknown section, so edit the document directly through Set.
+	if err := Set(path, "kdf", "argon2id"); err != nil {
+		t.Fatalf("Set(kdf) failed: %v", err)
+	}
+	if err := Set(path, "clipboard", "FALSE"); err != nil {
+		t.Fatalf("Set(clipboard) failed: %v", err)
+	}
+	if err := Set(path, "server", `http://host/"quoted"`); err != nil {
+		t.Fatalf("Set(server) failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# my settings\nkdf = \"argon2id\"\nclipboard = false\nserver = \"http://host/\\\"quoted\\\"\"\n\n[future]\n"
+	if string(data) != want {
+		t.Errorf("file =\n%s\nwant\n%s", data, want)
+	}
+
+	doc, err := readDocument(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := doc.get("server"); e.value.s != `http://host/"quoted"` {
+		t.Errorf("server round-trip = %q", e.value.s)
+	}
+
+	removed, err := Unset(path, "clipboard")
+	if err != nil || !removed {
+		t.Fatalf("Unset(clipboard) = %v, %v", removed, err)
+	}
+	if removed, _ := Unset(path, "clipboard"); removed {
+		t.Error("second Unset(clipboard) should report nothing removed")
+	}
+}
+
+func TestSetUnset_MultiLineValue(t *testing.T) {
+	path := isolate(t)
+	writeConfig(t, path, `server = """
+https://x.example"""
+kdf = '''
+scrypt'''
+
+[future]
+note = """
+[not.a.section]"""
+`)
+
+	if err := Set(path, "server", "https://y.example"); err != nil {
+		t.Fatalf("Set(server) failed: %v", err)
+	}
+	if removed, err := Unset(path, "kdf"); err != nil || !removed {
+		t.Fatalf("Unset(kdf) = %v, %v", removed, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `server = "https://y.example"
+
+[future]
+note = """
+[not.a.section]"""
+`
+	if string(data) != want {
+		t.Errorf("file =\n%s\nwant\n%s", data, want)
+	}
+
+	doc, err := readDocument(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := doc.get("future.note"); e.line != 4 || e.end != 5 {
+		t.Errorf("future.note lines = %d-%d, want 4-5", e.line, e.end)
+	}
+	if !doc.removeSection("future") || len(doc.lines) != 1 {
+		t.Errorf("removeSection(future) left %q", doc.lines)
+	}
+}
+
+func TestSet_Validates(t *testing.T) {
+	path := isolate(t)
+
+	if err := Set(path, "timeout", "soon"); err == nil {
+		t.Error("Set(timeout, soon) should fail")
+	}
+	if err := Set(path, "nope", "1"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Set(nope) error = %v, want ErrUnknownKey", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Error("failed Set should not create the config file")
+	}
+
+	if err := Set(path, "timeout", "45s"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("config mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Timeout != 45*time.Second {
+		t.Errorf("Timeout = %v, want 45s", cfg.Timeout)
+	}
+
+	t.Setenv("OTS_TIMEOUT", "1m")
+	OverrideTimeout(5 * time.Second)
+	defer OverrideTimeout(0)
+	cfg, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, source, _ := cfg.Get("timeout"); cfg.Timeout != 5*time.Second || source != SourceFlag {
+		t.Errorf("--timeout over OTS_TIMEOUT: timeout %s from %s, want 5s from flag", v, source)
+	}
+}
+
+func TestGetConfigPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("OTS_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+	t.Setenv("HOME", dir)
+
+	want := filepath.Join(dir, "xdg", "ots", FileName)
+	if got := GetConfigPath(); got != want {
+		t.Errorf("GetConfigPath() = %s, want %s", got, want)
+	}
+
+	legacy := filepath.Join(dir, ".otsconfig")
+	writeConfig(t, legacy, "")
+	if got := GetConfigPath(); got != legacy {
+		t.Errorf("GetConfigPath() with only the legacy file = %s, want %s", got, legacy)
+	}
+
+	t.Setenv("OTS_CONFIG", "/etc/ots.toml")
+	if got := GetConfigPath(); got != "/etc/ots.toml" {
+		t.Errorf("GetConfigPath() with OTS_CONFIG = %s", got)
+	}
+}
+
+const profilesConfig = `profile = "staging"
+
+[profiles.staging]
+server = "https://ots.staging.example.com"
+expires-in = "1h"
+
+[profiles.staging.headers]
+X-Team = "platform"
+
+[profiles.prod]
+server = "https://ots.example.com"
+client-cert = "/etc/ssl/me.pem"
+client-key = "/etc/ssl/me.key"
+pin = "sha256//AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+`
+
+func TestLoad_Profiles(t *testing.T) {
+	path := isolate(t)
+	writeConfig(t, path, profilesConfig)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.Profile != "staging" || cfg.ServerURL != "https://ots.staging.example.com" || cfg.ExpiresIn != "1h" {
+		t.Errorf("active profile not applied: %+v", cfg)
+	}
+	if _, source, _ := cfg.Get("server"); source != SourceProfile {
+		t.Errorf("server source = %s, want profile", source)
+	}
+	if got := cfg.ProfileNames(); len(got) != 2 || got[0] != "prod" || got[1] != "staging" {
+		t.Errorf("ProfileNames() = %v", got)
+	}
+
+	t.Setenv("OTS_PROFILE", "prod")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ServerURL != "https://ots.example.com" || cfg.ExpiresIn != DefaultExpiresIn {
+		t.Errorf("OTS_PROFILE=prod: server %s, expires-in %s", cfg.ServerURL, cfg.ExpiresIn)
+	}
+
+	SelectProfile("staging")
+	defer SelectProfile("")
+	t.Setenv("OTS_SERVER_URL", "http://localhost:3000")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Profile != "staging" || cfg.ServerURL != "http://localhost:3000" {
+		t.Errorf("--profile with OTS_SERVER_URL: profile %s, server %s", cfg.Profile, cfg.ServerURL)
+	}
+
+	SelectProfile("missing")
+	if _, err := Load(); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("Load() with unknown profile error = %v, want ErrUnknownProfile", err)
+	}
+}
+
+func TestLoad_ProfileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		line int
+		key  string
+	}{
+		{"no server", "[profiles.a]\nexpires-in = \"1h\"\n", 1, "profiles.a"},
+		{"headers only", "\n[profiles.a.headers]\nX-A = \"b\"\n", 2, "profiles.a.headers"},
+		{"unknown profile key", "[profiles.a]\nserver = \"http://a\"\nport = \"1\"\n", 3, "profiles.a.port"},
+		{"bad profile server", "[profiles.a]\nserver = \"ftp://a\"\n", 2, "profiles.a.server"},
+		{"header not string", "[profiles.a]\nserver = \"http://a\"\n[profiles.a.headers]\nX-N = 1\n", 4, "profiles.a.headers.X-N"},
+		{"unknown section", "[servers.a]\nserver = \"http://a\"\n", 2, "servers.a.server"},
+		{"empty unknown section", "[servers]\n", 1, "servers"},
+		{"bad proxy", "[profiles.a]\nserver = \"https://a\"\nproxy = \"ftp://p\"\n", 3, "profiles.a.proxy"},
+		{"bad pin", "[profiles.a]\nserver = \"https://a\"\npin = \"AAAA\"\n", 3, "profiles.a.pin"},
+		{"duplicate section", "[profiles.a]\nserver = \"http://a\"\n[profiles.a]\n", 3, "profiles.a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := isolate(t)
+			writeConfig(t, path, tt.text)
+
+			_, err := Load()
+			var cfgErr *Error
+			if !errors.As(err, &cfgErr) {
+				t.Fatalf("Load() error = %v, want *Error", err)
+			}
+			if cfgErr.Line != tt.line || cfgErr.Key != tt.key {
+				t.Errorf("error = %v, want line %d key %q", err, tt.line, tt.key)
+			}
+		})
+	}
+}
+
+func TestProfileFor(t *testing.T) {
+	path := isolate(t)
+	writeConfig(t, path, profilesConfig)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p := cfg.ProfileFor("https://OTS.example.com"); p == nil || p.Name != "prod" {
+		t.Errorf("ProfileFor(prod host) = %+v", p)
+	}
+	if p := cfg.ProfileFor("http://ots.example.com"); p != nil {
+		t.Errorf("ProfileFor() should not match a different scheme, got %s", p.Name)
+	}
+	if cfg.KnownServer("https://evil.example.com") {
+		t.Error("KnownServer(unrelated host) = true")
+	}
+
+	opts := clientOptions(t, cfg, "https://ots.staging.example.com")
+	if opts.Headers["X-Team"] != "platform" {
+		t.Errorf("staging headers not used: %+v", opts)
+	}
+	if opts := clientOptions(t, cfg, "https://evil.example.com"); len(opts.Headers) != 0 {
+		t.Errorf("profile headers sent to an unknown host: %+v", opts.Headers)
+	}
+
+	opts = clientOptions(t, cfg, "https://ots.example.com")
+	if opts.ClientCertFile != "/etc/ssl/me.pem" || opts.ClientKeyFile != "/etc/ssl/me.key" || opts.Pin == "" || opts.AllowInsecureHTTP {
+		t.Errorf("prod TLS settings not used: %+v", opts)
+	}
+	if opts := clientOptions(t, cfg, "https://evil.example.com"); opts.ClientCertFile != "" || opts.Pin != "" {
+		t.Errorf("profile client certificate offered to an unknown host: %+v", opts)
+	}
+}
+
+// clientOptions calls cfg.ClientOptions, failing the test on error.
+func clientOptions(t *testing.T, cfg *Config, server string) api.Options {
+	t.Helper()
+	opts, err := cfg.ClientOptions(server)
+	if err != nil {
+		t.Fatalf("ClientOptions(%s) failed: %v", server, err)
+	}
+	return opts
+}
+
+func TestClientOptions_Headers(t *testing.T) {
+	path := isolate(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("Bearer from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, path, `[profiles.gw]
+server = "https://gw.example.com"
+proxy = "http://proxy.example.com:3128"
+
+[profiles.gw.headers]
+Authorization = "file:`+tokenFile+`"
+X-Team = "env:OTS_TEST_TEAM"
+X-Static = "plain"
+`)
+	t.Setenv("OTS_TEST_TEAM", "platform")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := clientOptions(t, cfg, "https://gw.example.com")
+	want := map[string]string{"Authorization": "Bearer from-file", "X-Team": "platform", "X-Static": "plain"}
+	for name, value := range want {
+		if opts.Headers[name] != value {
+			t.Errorf("header %s = %q, want %q", name, opts.Headers[name], value)
+		}
+	}
+	if opts.Proxy != "http://proxy.example.com:3128" {
+		t.Errorf("Proxy = %q", opts.Proxy)
+	}
+
+	OverrideHeaders(map[string]string{"X-Team": "override", "X-Extra": "1"})
+	OverrideProxy("socks5://127.0.0.1:1080")
+	defer OverrideHeaders(nil)
+	defer OverrideProxy("")
+	opts = clientOptions(t, cfg, "https://gw.example.com")
+	if opts.Headers["X-Team"] != "override" || opts.Headers["X-Extra"] != "1" || opts.Headers["X-Static"] != "plain" {
+		t.Errorf("--header not merged over the profile: %v", opts.Headers)
+	}
+	if opts.Proxy != "socks5://127.0.0.1:1080" {
+		t.Errorf("--proxy not applied: %q", opts.Proxy)
+	}
+	if opts := clientOptions(t, cfg, "https://other.example.com"); opts.Headers["X-Extra"] != "1" || opts.Headers["Authorization"] != "" {
+		t.Errorf("headers for another server: %v", opts.Headers)
+	}
+
+	OverrideHeaders(map[string]string{"X-Missing": "env:OTS_TEST_UNSET"})
+	if _, err := cfg.ClientOptions("https://gw.example.com"); err == nil || !strings.Contains(err.Error(), "OTS_TEST_UNSET") {
+		t.Errorf("unset variable error = %v", err)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		arg     string
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"X-Team=platform", "X-Team", "platform", false},
+		{"Authorization=env:TOKEN", "Authorization", "env:TOKEN", false},
+		{"X-Eq=a=b", "X-Eq", "a=b", false},
+		{"X-Empty=", "X-Empty", "", false},
+		{"NoValue", "", "", true},
+		{"=value", "", "", true},
+		{"Bad Name=x", "", "", true},
+		{"X-Split=a\r\nInjected: 1", "", "", true},
+	}
+	for _, tt := range tests {
+		name, value, err := ParseHeader(tt.arg)
+		if (err != nil) != tt.wantErr || name != tt.name || value != tt.value {
+			t.Errorf("ParseHeader(%q) = %q, %q, %v", tt.arg, name, value, err)
+		}
+	}
+}
+
+func TestUnixServer(t *testing.T) {
+	for _, s := range []string{"unix:///run/ots.sock", "https://ots.example.com", "http://localhost:3000"} {
+		if err := ValidateServerURL(s); err != nil {
+			t.Errorf("ValidateServerURL(%q) = %v", s, err)
+		}
+	}
+	for _, s := range []string{"unix://run/ots.sock", "unix:relative.sock", "ftp://x"} {
+		if err := ValidateServerURL(s); err == nil {
+			t.Errorf("ValidateServerURL(%q) should fail", s)
+		}
+	}
+
+	if !sameOrigin("unix:///run/ots.sock", "unix:///run/ots.sock") || sameOrigin("unix:///run/a.sock", "unix:///run/b.sock") {
+		t.Error("unix servers should match by socket path")
+	}
+}
+
+func TestOverrideTLS(t *testing.T) {
+	path := isolate(t)
+	writeConfig(t, path, profilesConfig)
+
+	OverrideTLS(TLSOptions{CAFile: "/tmp/ca.pem", ClientCertFile: "/tmp/combined.pem", AllowInsecureHTTP: true})
+	defer OverrideTLS(TLSOptions{})
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := clientOptions(t, cfg, "https://ots.example.com")
+	if opts.CAFile != "/tmp/ca.pem" || opts.ClientCertFile != "/tmp/combined.pem" || !opts.AllowInsecureHTTP {
+		t.Errorf("overrides not applied: %+v", opts)
+	}
+	if opts.ClientKeyFile != "" {
+		t.Errorf("profile key %q paired with the --client-cert certificate", opts.ClientKeyFile)
+	}
+	if opts.Pin != "sha256//AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=" {
+		t.Errorf("profile pin dropped: %q", opts.Pin)
+	}
+}
+
+func TestAddUseRemoveProfile(t *testing.T) {
+	path := isolate(t)
+	writeConfig(t, path, "# settings\nkdf = \"scrypt\"\n")
+
+	pin := "sha256//" + strings.Repeat("B", 43) + "="
+	err := AddProfile(path, Profile{Name: "lab", ServerURL: "https://lab.example.com", ClientCert: path, Pin: pin, ExpiresIn: "2h", Headers: map[string]string{"X-Key": `a"b`}})
+	if err != nil {
+		t.Fatalf("AddProfile() failed: %v", err)
+	}
+	if err := AddProfile(path, Profile{Name: "lab", ServerURL: "https://other.example.com"}); err == nil {
+		t.Error("adding a duplicate profile should fail")
+	}
+	if err := AddProfile(path, Profile{Name: "bad name", ServerURL: "https://x"}); err == nil {
+		t.Error("invalid profile name should fail")
+	}
+	if err := AddProfile(path, Profile{Name: "x", ServerURL: "https://x", CAFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("missing CA file should fail")
+	}
+	if err := AddProfile(path, Profile{Name: "x", ServerURL: "https://x", ClientKey: path}); err == nil {
+		t.Error("client key without a certificate should fail")
+	}
+	if err := AddProfile(path, Profile{Name: "x", ServerURL: "https://x", Pin: "sha256//short"}); err == nil {
+		t.Error("invalid pin should fail")
+	}
+	if err := UseProfile(path, "nope"); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("UseProfile(nope) error = %v", err)
+	}
+	if err := UseProfile(path, "lab"); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() after AddProfile failed: %v", err)
+	}
+	if cfg.Profile != "lab" || cfg.ServerURL != "https://lab.example.com" || cfg.ExpiresIn != "2h" || cfg.KDF != "scrypt" {
+		t.Errorf("profile not active: %+v", cfg)
+	}
+	if got := cfg.Profiles["lab"].Headers["X-Key"]; got != `a"b` {
+		t.Errorf("header round-trip = %q", got)
+	}
+	if p := cfg.Profiles["lab"]; p.ClientCert != path || p.Pin != pin {
+		t.Errorf("TLS round-trip: client-cert %q, pin %q", p.ClientCert, p.Pin)
+	}
+
+	if err := RemoveProfile(path, "lab"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveProfile(path, "lab"); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("second RemoveProfile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "# settings\nkdf = \"scrypt\"\n" {
+		t.Errorf("file after remove = %q", data)
+	}
+}
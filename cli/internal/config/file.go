@@ -0,0 +1,389 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// The config file is TOML:
+//
+//	# comment
+//	server = "https://ots.example.com"
+//	burn-after-read = true
+//
+//	[section.name]
+//	key = 'literal string'
+//
+// Settings are strings, booleans or integers. The file's lines are kept as they are
+// so `ots config set` can edit it without losing comments or ordering.
+
+// valueKind is the type of a value in the config file.
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindBool
+	kindInt
+)
+
+func (k valueKind) String() string {
+	switch k {
+	case kindBool:
+		return "boolean"
+	case kindInt:
+		return "integer"
+	}
+	return "string"
+}
+
+// value is a typed value from the config file. s holds its text form.
+type value struct {
+	kind valueKind
+	s    string
+}
+
+// entry is a key/value line in the config file.
+type entry struct {
+	// section is the enclosing table name, or empty at the top level
+	section string
+	key     string
+	value   value
+	// line is the 1-based line number, and end the last line of a value spanning several
+	line, end int
+}
+
+// name returns the entry's full dotted key.
+func (e entry) name() string {
+	if e.section == "" {
+		return e.key
+	}
+	return e.section + "." + e.key
+}
+
+// Error is a config file or environment error that points at the offending key.
+type Error struct {
+	// Source is the file path, or the environment variable name
+	Source string
+	// Line is the 1-based line number, or 0 when the error is not tied to a line
+	Line int
+	// Key is the config key, if known
+	Key string
+	Err error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Source)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%d", e.Line)
+	}
+	if e.Key != "" {
+		fmt.Fprintf(&b, ": %s", e.Key)
+	}
+	fmt.Fprintf(&b, ": %v", e.Err)
+	return b.String()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// document is a parsed config file.
+type document struct {
+	path    string
+	lines   []string
+	entries []entry
+	// sections maps each section name to the line number of its header
+	sections map[string]int
+}
+
+// readDocument reads and parses the config file at path. A missing file is an empty document.
+func readDocument(path string) (*document, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &document{path: path, sections: make(map[string]int)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	return parseDocument(path, string(data))
+}
+
+// parseDocument parses config file text.
+func parseDocument(path, text string) (*document, error) {
+	doc := &document{path: path, sections: make(map[string]int)}
+	text = strings.TrimSuffix(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if text != "" {
+		doc.lines = strings.Split(text, "\n")
+	}
+
+	var tree map[string]interface{}
+	md, err := toml.Decode(text, &tree)
+	if err != nil {
+		var parseErr toml.ParseError
+		if !errors.As(err, &parseErr) {
+			return nil, &Error{Source: path, Err: err}
+		}
+		// An error at the end of the last line is reported on the line after it
+		line := min(parseErr.Position.Line, len(doc.lines))
+		key := parseErr.LastKey
+		if line > 0 {
+			// The last key before a duplicate table is its parent, so name the table itself
+			if table, header := lineKey(doc.lines[line-1]); header {
+				key = table.String()
+			}
+		}
+		return nil, &Error{Source: path, Line: line, Key: key, Err: errors.New(parseErr.Message)}
+	}
+
+	lines := keyLines(doc.lines)
+	for _, key := range md.Keys() {
+		name := key.String()
+		if md.Type(key...) == "Hash" {
+			doc.sections[name] = lines[name].first
+			continue
+		}
+
+		e := entry{key: key[len(key)-1], line: lines[name].first, end: lines[name].last}
+		if len(key) > 1 {
+			e.section = key[:len(key)-1].String()
+		}
+		v, err := newValue(lookup(tree, key))
+		if err != nil {
+			return nil, &Error{Source: path, Line: e.line, Key: name, Err: err}
+		}
+		e.value = v
+		doc.entries = append(doc.entries, e)
+	}
+	return doc, nil
+}
+
+// newValue converts a decoded TOML value.
+func newValue(v interface{}) (value, error) {
+	switch v := v.(type) {
+	case string:
+		return value{kind: kindString, s: v}, nil
+	case bool:
+		return value{kind: kindBool, s: strconv.FormatBool(v)}, nil
+	case int64:
+		return value{kind: kindInt, s: strconv.FormatInt(v, 10)}, nil
+	}
+	return value{}, fmt.Errorf("unsupported value %v (expected a string, boolean or integer)", v)
+}
+
+// lookup returns the decoded value at key.
+func lookup(tree map[string]interface{}, key toml.Key) interface{} {
+	var v interface{} = tree
+	for _, k := range key {
+		table, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = table[k]
+	}
+	return v
+}
+
+// span is the 1-based first and last line of a key or table header.
+type span struct{ first, last int }
+
+// keyLines maps the full name of each key and table header to the lines defining it.
+func keyLines(lines []string) map[string]span {
+	located := make(map[string]span)
+	var table toml.Key
+	for i := 0; i < len(lines); i++ {
+		key, header, last := statementKey(lines, i)
+		if key != nil {
+			if header {
+				table = key
+			} else {
+				key = append(table[:len(table):len(table)], key...)
+			}
+			if _, ok := located[key.String()]; !ok {
+				located[key.String()] = span{first: i + 1, last: last + 1}
+			}
+		}
+		i = last
+	}
+	return located
+}
+
+// statementKey returns the key set or the table opened by the statement starting at lines[i],
+// and the index of its last line. A line that does not decode on its own, such as the start
+// of a multi-line string, is joined with the lines after it until it does.
+func statementKey(lines []string, i int) (key toml.Key, header bool, last int) {
+	for j := i; j < len(lines); j++ {
+		var v map[string]interface{}
+		md, err := toml.Decode(strings.Join(lines[i:j+1], "\n"), &v)
+		if err != nil {
+			continue
+		}
+		if len(md.Keys()) == 0 {
+			return nil, false, j
+		}
+		return md.Keys()[0], strings.HasPrefix(strings.TrimSpace(lines[i]), "["), j
+	}
+	return nil, false, i
+}
+
+// lineKey returns the key set or the table opened by a single line, if it holds one.
+func lineKey(raw string) (key toml.Key, header bool) {
+	key, header, _ = statementKey([]string{raw}, 0)
+	return key, header
+}
+
+// validKey reports whether key is a TOML bare key, which can be written without quotes.
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeValue formats a value for the config file.
+func encodeValue(v value) string {
+	if v.kind != kindString {
+		return v.s
+	}
+	return `"` + escaper.Replace(v.s) + `"`
+}
+
+// escaper escapes the characters a TOML basic string cannot hold as they are
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+
+// get returns the entry for a full dotted key.
+func (d *document) get(name string) (entry, bool) {
+	for _, e := range d.entries {
+		if e.name() == name {
+			return e, true
+		}
+	}
+	return entry{}, false
+}
+
+// set writes a top-level key, replacing its lines or adding one before the first section.
+func (d *document) set(key string, v value) {
+	line := fmt.Sprintf("%s = %s", key, encodeValue(v))
+	if e, ok := d.get(key); ok {
+		d.lines = append(d.lines[:e.line-1], append([]string{line}, d.lines[e.end:]...)...)
+		return
+	}
+
+	// Insert after the last top-level entry, or else before the first section header.
+	lastTop, firstSection := -1, len(d.lines)
+	for i, raw := range d.lines {
+		text := strings.TrimSpace(raw)
+		if strings.HasPrefix(text, "[") {
+			firstSection = i
+			break
+		}
+		if text != "" && !strings.HasPrefix(text, "#") {
+			lastTop = i
+		}
+	}
+
+	switch {
+	case lastTop >= 0:
+		d.insert(lastTop+1, line)
+	case firstSection < len(d.lines):
+		d.insert(firstSection, line+"\n")
+	default:
+		d.insert(len(d.lines), line)
+	}
+}
+
+// unset removes a top-level key. It reports whether the key was present.
+func (d *document) unset(key string) bool {
+	e, ok := d.get(key)
+	if !ok {
+		return false
+	}
+	d.lines = append(d.lines[:e.line-1], d.lines[e.end:]...)
+	return true
+}
+
+// removeSection removes the section name and any sections nested under it,
+// along with the blank lines before them. It reports whether anything was removed.
+func (d *document) removeSection(name string) bool {
+	var kept []string
+	removed, inside := false, false
+	for i := 0; i < len(d.lines); i++ {
+		key, header, last := statementKey(d.lines, i)
+		if header {
+			table := key.String()
+			wasInside := inside
+			inside = table == name || strings.HasPrefix(table, name+".")
+			if wasInside && !inside && len(kept) > 0 {
+				kept = append(kept, "")
+			}
+			if inside {
+				removed = true
+				for len(kept) > 0 && strings.TrimSpace(kept[len(kept)-1]) == "" {
+					kept = kept[:len(kept)-1]
+				}
+			}
+		}
+		if !inside {
+			kept = append(kept, d.lines[i:last+1]...)
+		}
+		i = last
+	}
+	d.lines = kept
+	return removed
+}
+
+// appendText adds text at the end of the file, separated by a blank line.
+func (d *document) appendText(text string) {
+	if len(d.lines) > 0 && strings.TrimSpace(d.lines[len(d.lines)-1]) != "" {
+		text = "\n" + text
+	}
+	d.insert(len(d.lines), text)
+}
+
+// insert adds text, which may span several lines, before line index at.
+func (d *document) insert(at int, text string) {
+	added := strings.Split(text, "\n")
+	lines := make([]string, 0, len(d.lines)+len(added))
+	lines = append(lines, d.lines[:at]...)
+	lines = append(lines, added...)
+	d.lines = append(lines, d.lines[at:]...)
+}
+
+// save writes the document atomically with owner-only permissions.
+func (d *document) save() error {
+	dir := filepath.Dir(d.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create config directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(d.path)+".*")
+	if err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	text := strings.Join(d.lines, "\n")
+	if text != "" {
+		text += "\n"
+	}
+	if _, err := tmp.WriteString(text); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), d.path); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	return nil
+}
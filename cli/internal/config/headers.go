@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Header values starting with these prefixes are read when a request is made,
+// so a token need not be written into the config file or shell history.
+const (
+	envPrefix  = "env:"
+	filePrefix = "file:"
+)
+
+// ParseHeader splits a Name=value header argument.
+func ParseHeader(s string) (name, value string, err error) {
+	name, value, ok := strings.Cut(s, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("invalid header %q (expected Name=value)", s)
+	}
+	if !validKey(name) {
+		return "", "", fmt.Errorf("invalid header name %q", name)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return "", "", fmt.Errorf("header %s: value cannot contain line breaks", name)
+	}
+	return name, value, nil
+}
+
+// resolveHeaders returns headers with env:NAME values replaced by the environment variable
+// and file:PATH values by the file's contents, without the trailing newline.
+func resolveHeaders(headers map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(headers))
+	for name, value := range headers {
+		v, err := resolveHeaderValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", name, err)
+		}
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, fmt.Errorf("header %s: value cannot contain line breaks", name)
+		}
+		resolved[name] = v
+	}
+	return resolved, nil
+}
+
+// resolveHeaderValue reads an env: or file: header value; other values are returned as is.
+func resolveHeaderValue(value string) (string, error) {
+	if name, ok := strings.CutPrefix(value, envPrefix); ok {
+		v, set := os.LookupEnv(name)
+		if !set {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return v, nil
+	}
+	if path, ok := strings.CutPrefix(value, filePrefix); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return value, nil
+}
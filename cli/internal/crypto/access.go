@@ -0,0 +1,50 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Access passwords gate retrieval on the server, separately from the encryption password.
+// The client sends an Argon2id hash in PHC format when creating the secret, and the same
+// hash when retrieving it. The salt is derived from the link's encryption key, which the
+// server never sees, so a stored hash cannot be attacked offline without the link.
+const (
+	// AccessArgon2Time is the Argon2id time cost for access password hashes
+	AccessArgon2Time = 3
+	// AccessArgon2Memory is the Argon2id memory cost in KiB for access password hashes
+	AccessArgon2Memory = 64 * 1024
+	// AccessArgon2Parallelism is the Argon2id parallelism for access password hashes
+	AccessArgon2Parallelism = 4
+
+	// accessSaltContext separates the access salt from any other use of the key
+	accessSaltContext = "ots-access-password-v1"
+)
+
+// AccessPasswordHash returns the PHC-encoded Argon2id hash of an access password,
+// salted with a value derived from the hex-encoded link key.
+// The same password and key always produce the same hash.
+func AccessPasswordHash(password, key string) (string, error) {
+	if password == "" {
+		return "", fmt.Errorf("access password cannot be empty")
+	}
+
+	keyBytes, err := hex.DecodeString(key)
+	if err != nil || len(keyBytes) != KeySize {
+		return "", fmt.Errorf("invalid key")
+	}
+
+	digest := sha256.Sum256(append([]byte(accessSaltContext), keyBytes...))
+	salt := digest[:SaltSize]
+
+	hash := argon2.IDKey([]byte(password), salt, AccessArgon2Time, AccessArgon2Memory, AccessArgon2Parallelism, KeySize)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, AccessArgon2Memory, AccessArgon2Time, AccessArgon2Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash)), nil
+}
@@ -0,0 +1,47 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAccessPasswordHash(t *testing.T) {
+	key := strings.Repeat("ab", KeySize)
+	otherKey := strings.Repeat("cd", KeySize)
+
+	hash, err := AccessPasswordHash("open sesame", key)
+	if err != nil {
+		t.Fatalf("AccessPasswordHash() failed: %v", err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=4$") {
+		t.Errorf("hash is not a PHC argon2id string: %s", hash)
+	}
+	if len(hash) > 512 {
+		t.Errorf("hash is %d characters, the server accepts at most 512", len(hash))
+	}
+
+	again, _ := AccessPasswordHash("open sesame", key)
+	if again != hash {
+		t.Error("hash should be deterministic for the same password and key")
+	}
+
+	if other, _ := AccessPasswordHash("open sesame!", key); other == hash {
+		t.Error("different passwords produced the same hash")
+	}
+	if other, _ := AccessPasswordHash("open sesame", otherKey); other == hash {
+		t.Error("different keys produced the same hash")
+	}
+}
+
+func TestAccessPasswordHash_Invalid(t *testing.T) {
+	if _, err := AccessPasswordHash("", strings.Repeat("ab", KeySize)); err == nil {
+		t.Error("empty password should fail")
+	}
+	if _, err := AccessPasswordHash("pw", "not-hex"); err == nil {
+		t.Error("invalid key should fail")
+	}
+	if _, err := AccessPasswordHash("pw", "abcd"); err == nil {
+		t.Error("short key should fail")
+	}
+}
@@ -1,19 +1,18 @@
 // Package crypto provides client-side encryption for one-time secrets.
-// Uses AES-256-CBC with PBKDF2-SHA1 for password-based key derivation.
+// New secrets use an authenticated AES-256-GCM envelope with a choice of password KDF;
+// the legacy AES-256-CBC / PBKDF2-SHA1 format produced by the web interface is still supported.
 package crypto
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
-	"crypto/sha1"
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"strings"
-
-	"golang.org/x/crypto/pbkdf2"
 )
 
 const (
@@ -25,8 +24,22 @@ const (
 	IVSize = 16
 	// SaltSize is the salt size in bytes
 	SaltSize = 16
+	// NonceSize is the AES-GCM nonce size in bytes (96 bits)
+	NonceSize = 12
 	// passwordPrefix marks password-protected secrets
 	passwordPrefix = "PWD:"
+	// saltedPasswordPrefix marks password-protected secrets whose PBKDF2 key is salted with EncryptedSecret.Salt
+	saltedPasswordPrefix = "PWS:"
+)
+
+const (
+	// VersionCBC is the legacy envelope format shared with the web interface:
+	// AES-256-CBC with PKCS7 padding for both layers, no authentication.
+	VersionCBC = 1
+	// VersionGCM is the authenticated envelope format: AES-256-GCM for both layers.
+	VersionGCM = 2
+	// DefaultVersion is the envelope format used for new secrets.
+	DefaultVersion = VersionGCM
 )
 
 var (
@@ -36,26 +49,102 @@ var (
 	ErrInvalidPadding = errors.New("invalid padding")
 	// ErrShortCiphertext is returned when ciphertext is too short
 	ErrShortCiphertext = errors.New("ciphertext too short")
+	// ErrDecryptionFailed is returned when the key or password is wrong or the ciphertext was modified
+	ErrDecryptionFailed = errors.New("decryption failed: wrong key or password, or data was tampered with")
+	// ErrUnsupportedVersion is returned for envelope versions this client does not understand
+	ErrUnsupportedVersion = errors.New("unsupported encryption format version")
 )
 
 // EncryptedSecret represents an encrypted secret with its metadata.
+// Version identifies the envelope format and is recorded in kdfParams.version;
+// a zero Version is treated as VersionCBC so that web-created secrets keep working.
 type EncryptedSecret struct {
 	Ciphertext string
 	IV         string
 	Salt       string
 	Key        string
+	Version    int
+	// KDF describes how the password layer key is derived.
+	// A zero value is the legacy PBKDF2-SHA1 scheme.
+	KDF KDFParams
+}
+
+// Options controls how EncryptSecretWithOptions builds the envelope.
+type Options struct {
+	// Version selects the envelope format. Zero means DefaultVersion.
+	Version int
+	// KDF selects the password KDF and its cost. A zero value means
+	// argon2id for VersionGCM and the legacy PBKDF2-SHA1 scheme for VersionCBC.
+	// Any salt is ignored; a fresh one is generated for every secret.
+	// It is unused when no password is given.
+	KDF KDFParams
+}
+
+// EncryptSecret encrypts plaintext with optional password protection using the default envelope format.
+func EncryptSecret(plaintext string, password string) (*EncryptedSecret, error) {
+	return EncryptSecretWithOptions(plaintext, password, Options{})
+}
+
+// EncryptSecretWithOptions encrypts plaintext with optional password protection.
+// It is the string form of EncryptBytes.
+func EncryptSecretWithOptions(plaintext string, password string, opts Options) (*EncryptedSecret, error) {
+	return EncryptBytes([]byte(plaintext), password, opts)
 }
 
-// EncryptSecret encrypts plaintext with optional password protection using layered encryption.
-// Matches web implementation: password encryption uses SHA1-based PBKDF2, separate IVs for each layer.
+// EncryptBytes encrypts arbitrary data with optional password protection using layered encryption.
+// The data is never converted to a string, so binary content survives unchanged.
+// Each layer uses its own random IV (CBC) or nonce (GCM).
 //
 // If password is provided:
-//   - Inner layer: Secret encrypted with password using PBKDF2-SHA1 (10k iterations) + AES-256-CBC
-//   - Outer layer: Password-encrypted result encrypted with random 256-bit key + AES-256-CBC
+//   - Inner layer: Secret encrypted with a key derived from the password by opts.KDF
+//   - Outer layer: Password-encrypted result encrypted with random 256-bit key
 //
 // If no password:
-//   - Single layer: Secret encrypted with random 256-bit key + AES-256-CBC
-func EncryptSecret(plaintext string, password string) (*EncryptedSecret, error) {
+//   - Single layer: Secret encrypted with random 256-bit key
+//
+// VersionGCM authenticates both layers, so a wrong key or password yields ErrDecryptionFailed.
+// VersionCBC matches the web implementation byte for byte.
+func EncryptBytes(plaintext []byte, password string, opts Options) (*EncryptedSecret, error) {
+	version := opts.Version
+	if version == 0 {
+		version = DefaultVersion
+	}
+	if version != VersionCBC && version != VersionGCM {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	// Without a password there is no inner layer, so record the plain legacy KDF
+	kdf := opts.KDF
+	if password == "" {
+		kdf = KDFParams{Name: KDFPBKDF2}
+	}
+	if kdf.Name == "" {
+		kdf.Name = KDFPBKDF2
+		if version == VersionGCM {
+			kdf, _ = DefaultKDFParams(KDFArgon2id)
+		}
+	}
+	if err := kdf.Validate(); err != nil {
+		return nil, err
+	}
+	kdf.Salt = ""
+	if kdf.Name == KDFPBKDF2 {
+		// The legacy web scheme has a fixed iteration count; the salted mode records its own
+		kdf.Iterations = 0
+		if password != "" && version != VersionCBC {
+			kdf.Iterations = opts.KDF.Iterations
+			if kdf.Iterations == 0 {
+				kdf.Iterations = PBKDF2SaltedIterations
+			}
+		}
+	} else {
+		kdfSalt, err := randomBytes(SaltSize)
+		if err != nil {
+			return nil, fmt.Errorf("generate KDF salt: %w", err)
+		}
+		kdf.Salt = hex.EncodeToString(kdfSalt)
+	}
+
 	// Generate random components
 	salt, err := randomBytes(SaltSize)
 	if err != nil {
@@ -67,7 +156,7 @@ func EncryptSecret(plaintext string, password string) (*EncryptedSecret, error)
 		return nil, fmt.Errorf("generate outer key: %w", err)
 	}
 
-	outerIV, err := randomBytes(IVSize)
+	outerIV, err := randomBytes(ivSize(version))
 	if err != nil {
 		return nil, fmt.Errorf("generate outer IV: %w", err)
 	}
@@ -75,14 +164,14 @@ func EncryptSecret(plaintext string, password string) (*EncryptedSecret, error)
 	// Prepare payload (encrypt with password if provided)
 	payload := plaintext
 	if password != "" {
-		payload, err = encryptWithPassword(plaintext, password)
+		payload, err = encryptWithPassword(plaintext, password, version, kdf, salt)
 		if err != nil {
 			return nil, fmt.Errorf("encrypt with password: %w", err)
 		}
 	}
 
 	// Encrypt payload with outer key
-	ciphertext, err := encryptAES([]byte(payload), outerKey, outerIV)
+	ciphertext, err := seal(version, payload, outerKey, outerIV)
 	if err != nil {
 		return nil, fmt.Errorf("encrypt outer layer: %w", err)
 	}
@@ -92,106 +181,241 @@ func EncryptSecret(plaintext string, password string) (*EncryptedSecret, error)
 		IV:         hex.EncodeToString(outerIV),
 		Salt:       hex.EncodeToString(salt),
 		Key:        hex.EncodeToString(outerKey),
+		Version:    version,
+		KDF:        kdf,
 	}, nil
 }
 
 // DecryptSecret decrypts an encrypted secret using the outer key and optional password.
-// Matches web implementation: decrypts outer layer first, then checks for password protection.
+// It is the string form of DecryptBytes.
+func DecryptSecret(enc *EncryptedSecret, password string) (string, error) {
+	plaintext, err := DecryptBytes(enc, password)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// DecryptBytes decrypts an encrypted secret using the outer key and optional password.
+// The envelope format is selected by enc.Version.
 //
 // Process:
 //  1. Decrypt outer layer using the provided key
 //  2. Check if result has password prefix
 //  3. If password-protected, decrypt inner layer using provided password
-func DecryptSecret(enc *EncryptedSecret, password string) (string, error) {
+func DecryptBytes(enc *EncryptedSecret, password string) ([]byte, error) {
+	version := enc.Version
+	if version == 0 {
+		version = VersionCBC
+	}
+	if version != VersionCBC && version != VersionGCM {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
 	// Decode components
 	outerKey, err := hex.DecodeString(enc.Key)
 	if err != nil {
-		return "", fmt.Errorf("decode key: %w", err)
+		return nil, fmt.Errorf("decode key: %w", err)
 	}
 
 	outerIV, err := hex.DecodeString(enc.IV)
 	if err != nil {
-		return "", fmt.Errorf("decode IV: %w", err)
+		return nil, fmt.Errorf("decode IV: %w", err)
 	}
 
 	ciphertext, err := base64.StdEncoding.DecodeString(enc.Ciphertext)
 	if err != nil {
-		return "", fmt.Errorf("decode ciphertext: %w", err)
+		return nil, fmt.Errorf("decode ciphertext: %w", err)
 	}
 
 	// Decrypt outer layer
-	payload, err := decryptAES(ciphertext, outerKey, outerIV)
+	payload, err := open(version, ciphertext, outerKey, outerIV)
 	if err != nil {
-		return "", fmt.Errorf("decrypt outer layer: %w", err)
+		return nil, fmt.Errorf("decrypt outer layer: %w", err)
 	}
 
-	// Check if password-protected (by checking for PWD: prefix)
-	if strings.HasPrefix(string(payload), passwordPrefix) {
+	// Check if password-protected (by checking for PWD: or PWS: prefix)
+	if isPasswordProtected(payload) {
 		if password == "" {
-			return "", ErrPasswordRequired
+			return nil, ErrPasswordRequired
 		}
-		return decryptWithPassword(string(payload), password)
+		return decryptWithPassword(payload, password, version, enc.KDF, enc.Salt)
+	}
+
+	return payload, nil
+}
+
+// VersionFromParams returns the envelope version recorded in kdfParams.
+// Secrets without a version (such as those created by the web interface) are VersionCBC.
+func VersionFromParams(params map[string]interface{}) int {
+	if version := intParam(params, "version"); version != 0 {
+		return version
 	}
+	return VersionCBC
+}
 
-	return string(payload), nil
+// isPasswordProtected reports whether a decrypted outer payload carries a password layer.
+func isPasswordProtected(payload []byte) bool {
+	return bytes.HasPrefix(payload, []byte(passwordPrefix)) || bytes.HasPrefix(payload, []byte(saltedPasswordPrefix))
 }
 
-// encryptWithPassword encrypts plaintext with password using PBKDF2-SHA1 (matching web CryptoJS).
-// Uses empty salt (nil) and generates a separate IV for password encryption.
-func encryptWithPassword(plaintext, password string) (string, error) {
+// encryptWithPassword encrypts plaintext with a key derived from password by kdf.
+// With VersionCBC the pbkdf2 KDF matches web CryptoJS (PBKDF2-SHA1, empty salt);
+// otherwise it is salted with the per-secret salt and marked "PWS:".
+// Generates a separate IV for password encryption.
+func encryptWithPassword(plaintext []byte, password string, version int, kdf KDFParams, salt []byte) ([]byte, error) {
 	// Generate separate IV for password encryption
-	passwordIV, err := randomBytes(IVSize)
+	passwordIV, err := randomBytes(ivSize(version))
 	if err != nil {
-		return "", fmt.Errorf("generate password IV: %w", err)
+		return nil, fmt.Errorf("generate password IV: %w", err)
 	}
 
-	// Use SHA1 for PBKDF2 to match CryptoJS default (web implementation)
-	key := pbkdf2.Key([]byte(password), nil, PBKDF2Iterations, KeySize, sha1.New)
+	prefix := passwordPrefix
+	var key []byte
+	if kdf.Name == KDFPBKDF2 && version != VersionCBC {
+		prefix = saltedPasswordPrefix
+		key, err = kdf.deriveSaltedKey(password, salt)
+	} else {
+		key, err = kdf.deriveKey(password)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("derive password key: %w", err)
+	}
 
-	ciphertext, err := encryptAES([]byte(plaintext), key, passwordIV)
+	ciphertext, err := seal(version, plaintext, key, passwordIV)
 	if err != nil {
-		return "", fmt.Errorf("encrypt with password key: %w", err)
+		return nil, fmt.Errorf("encrypt with password key: %w", err)
 	}
 
-	// Format: "PWD:base64_ciphertext||hex_iv" (matching web implementation)
-	return fmt.Sprintf("%s%s||%s",
-		passwordPrefix,
+	// Format: "PWD:base64_ciphertext||hex_iv" (matching web implementation), or "PWS:..." when salted
+	return []byte(fmt.Sprintf("%s%s||%s",
+		prefix,
 		base64.StdEncoding.EncodeToString(ciphertext),
 		hex.EncodeToString(passwordIV),
-	), nil
+	)), nil
 }
 
-// decryptWithPassword decrypts password-protected payload using PBKDF2-SHA1 (matching web CryptoJS).
-func decryptWithPassword(payload, password string) (string, error) {
-	// Remove prefix: "PWD:"
-	if !strings.HasPrefix(payload, passwordPrefix) {
-		return "", fmt.Errorf("invalid password-encrypted format")
+// decryptWithPassword decrypts password-protected payload with a key derived from password by kdf.
+// The payload marker selects the unsalted ("PWD:") or salted ("PWS:") derivation;
+// saltHex is the per-secret salt used by the latter.
+func decryptWithPassword(payload []byte, password string, version int, kdf KDFParams, saltHex string) ([]byte, error) {
+	// Remove prefix: "PWD:" or "PWS:"
+	var prefix string
+	switch {
+	case bytes.HasPrefix(payload, []byte(passwordPrefix)):
+		prefix = passwordPrefix
+	case bytes.HasPrefix(payload, []byte(saltedPasswordPrefix)):
+		prefix = saltedPasswordPrefix
+	default:
+		return nil, fmt.Errorf("invalid password-encrypted format")
 	}
 
-	parts := strings.Split(payload[len(passwordPrefix):], "||")
+	parts := strings.Split(string(payload[len(prefix):]), "||")
 	if len(parts) != 2 {
-		return "", fmt.Errorf("invalid password-encrypted format: expected format PWD:ciphertext||iv")
+		return nil, fmt.Errorf("invalid password-encrypted format: expected format PWD:ciphertext||iv")
 	}
 
 	ciphertext, err := base64.StdEncoding.DecodeString(parts[0])
 	if err != nil {
-		return "", fmt.Errorf("decode ciphertext: %w", err)
+		return nil, fmt.Errorf("decode ciphertext: %w", err)
 	}
 
 	passwordIV, err := hex.DecodeString(parts[1])
 	if err != nil {
-		return "", fmt.Errorf("decode password IV: %w", err)
+		return nil, fmt.Errorf("decode password IV: %w", err)
 	}
 
-	// Use SHA1 for PBKDF2 to match CryptoJS default (web implementation)
-	key := pbkdf2.Key([]byte(password), nil, PBKDF2Iterations, KeySize, sha1.New)
+	var key []byte
+	if prefix == saltedPasswordPrefix {
+		var salt []byte
+		salt, err = hex.DecodeString(saltHex)
+		if err != nil {
+			return nil, fmt.Errorf("decode salt: %w", err)
+		}
+		key, err = kdf.deriveSaltedKey(password, salt)
+	} else {
+		key, err = kdf.deriveKey(password)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("derive password key: %w", err)
+	}
 
-	plaintext, err := decryptAES(ciphertext, key, passwordIV)
+	plaintext, err := open(version, ciphertext, key, passwordIV)
 	if err != nil {
-		return "", fmt.Errorf("decrypt with password: %w", err)
+		return nil, fmt.Errorf("decrypt with password: %w", err)
 	}
 
-	return string(plaintext), nil
+	return plaintext, nil
+}
+
+// ivSize returns the IV or nonce length used by the given envelope version.
+func ivSize(version int) int {
+	if version == VersionGCM {
+		return NonceSize
+	}
+	return IVSize
+}
+
+// seal encrypts plaintext with the cipher selected by the envelope version.
+func seal(version int, plaintext, key, iv []byte) ([]byte, error) {
+	if version == VersionGCM {
+		return encryptGCM(plaintext, key, iv)
+	}
+	return encryptAES(plaintext, key, iv)
+}
+
+// open decrypts ciphertext with the cipher selected by the envelope version.
+// Any failure that can be caused by a wrong key or modified data wraps ErrDecryptionFailed.
+func open(version int, ciphertext, key, iv []byte) ([]byte, error) {
+	if version == VersionGCM {
+		return decryptGCM(ciphertext, key, iv)
+	}
+	plaintext, err := decryptAES(ciphertext, key, iv)
+	if errors.Is(err, ErrInvalidPadding) {
+		return nil, fmt.Errorf("%w: %w", ErrDecryptionFailed, err)
+	}
+	return plaintext, err
+}
+
+// encryptGCM encrypts plaintext using AES-256-GCM. The authentication tag is appended to the ciphertext.
+func encryptGCM(plaintext, key, nonce []byte) ([]byte, error) {
+	aead, err := newGCM(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, nonce, plaintext, nil), nil
+}
+
+// decryptGCM decrypts and authenticates ciphertext using AES-256-GCM.
+func decryptGCM(ciphertext, key, nonce []byte) ([]byte, error) {
+	aead, err := newGCM(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.Overhead() {
+		return nil, ErrShortCiphertext
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+// newGCM creates an AES-GCM AEAD for the given key and checks the nonce length.
+func newGCM(key, nonce []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length: %d", len(nonce))
+	}
+	return aead, nil
 }
 
 // encryptAES encrypts plaintext using AES-256-CBC with PKCS7 padding.
@@ -215,6 +439,9 @@ func decryptAES(ciphertext, key, iv []byte) ([]byte, error) {
 	if len(ciphertext) < aes.BlockSize {
 		return nil, ErrShortCiphertext
 	}
+	if len(ciphertext)%aes.BlockSize != 0 || len(iv) != aes.BlockSize {
+		return nil, ErrInvalidPadding
+	}
 
 	block, err := aes.NewCipher(key)
 	if err != nil {
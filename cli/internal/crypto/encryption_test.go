@@ -1,6 +1,10 @@
 package crypto
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"testing"
 )
 
@@ -64,15 +68,171 @@ func TestEncryptSecret_WithPassword(t *testing.T) {
 		t.Errorf("Expected ErrPasswordRequired, got: %v", err)
 	}
 
-	// Try decrypting with wrong password - should fail or produce garbage
+	// Try decrypting with wrong password - authenticated format must fail
+	_, err = DecryptSecret(encrypted, "wrongpassword")
+	if !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("Expected ErrDecryptionFailed, got: %v", err)
+	}
+}
+
+func TestEncryptSecret_LegacyCBC(t *testing.T) {
+	plaintext := "Secret message"
+	password := "mypassword123"
+
+	encrypted, err := EncryptSecretWithOptions(plaintext, password, Options{Version: VersionCBC})
+	if err != nil {
+		t.Fatalf("EncryptSecretWithOptions failed: %v", err)
+	}
+
+	if encrypted.Version != VersionCBC {
+		t.Errorf("Expected version %d, got %d", VersionCBC, encrypted.Version)
+	}
+	if len(encrypted.IV) != IVSize*2 {
+		t.Errorf("Legacy IV should be %d bytes, got %q", IVSize, encrypted.IV)
+	}
+
+	// Web-created secrets carry no version, so zero must select the legacy path
+	encrypted.Version = 0
+	decrypted, err := DecryptSecret(encrypted, password)
+	if err != nil {
+		t.Fatalf("DecryptSecret failed: %v", err)
+	}
+
+	if decrypted != plaintext {
+		t.Errorf("Decrypted text doesn't match. Expected: %q, Got: %q", plaintext, decrypted)
+	}
+
+	// Try decrypting with wrong password - CBC may fail or produce garbage
 	wrongDecrypted, err := DecryptSecret(encrypted, "wrongpassword")
-	if err == nil {
-		// If no error, the decrypted text should be garbage (not the original)
-		if wrongDecrypted == plaintext {
-			t.Error("Wrong password should not produce the original plaintext")
+	if err == nil && wrongDecrypted == plaintext {
+		t.Error("Wrong password should not produce the original plaintext")
+	}
+}
+
+func TestEncryptSecret_DefaultVersion(t *testing.T) {
+	encrypted, err := EncryptSecret("Test", "")
+	if err != nil {
+		t.Fatalf("EncryptSecret failed: %v", err)
+	}
+
+	if encrypted.Version != DefaultVersion {
+		t.Errorf("Expected version %d, got %d", DefaultVersion, encrypted.Version)
+	}
+	if len(encrypted.IV) != NonceSize*2 {
+		t.Errorf("GCM nonce should be %d bytes, got %q", NonceSize, encrypted.IV)
+	}
+}
+
+func TestEncryptSecret_UnsupportedVersion(t *testing.T) {
+	_, err := EncryptSecretWithOptions("Test", "", Options{Version: 99})
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got: %v", err)
+	}
+
+	encrypted, err := EncryptSecret("Test", "")
+	if err != nil {
+		t.Fatalf("EncryptSecret failed: %v", err)
+	}
+	encrypted.Version = 99
+	_, err = DecryptSecret(encrypted, "")
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got: %v", err)
+	}
+}
+
+func TestDecryptSecret_WrongKey(t *testing.T) {
+	encrypted, err := EncryptSecret("Test", "")
+	if err != nil {
+		t.Fatalf("EncryptSecret failed: %v", err)
+	}
+
+	other, err := EncryptSecret("Test", "")
+	if err != nil {
+		t.Fatalf("EncryptSecret failed: %v", err)
+	}
+
+	encrypted.Key = other.Key
+	_, err = DecryptSecret(encrypted, "")
+	if !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("Expected ErrDecryptionFailed, got: %v", err)
+	}
+}
+
+func TestDecryptSecret_TamperedCiphertext(t *testing.T) {
+	for _, password := range []string{"", "password"} {
+		encrypted, err := EncryptSecret("Tamper with me", password)
+		if err != nil {
+			t.Fatalf("EncryptSecret failed: %v", err)
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(encrypted.Ciphertext)
+		if err != nil {
+			t.Fatalf("decode ciphertext: %v", err)
 		}
+
+		// Flip one bit in every position; each must be detected
+		for i := range raw {
+			tampered := append([]byte(nil), raw...)
+			tampered[i] ^= 0x01
+			enc := *encrypted
+			enc.Ciphertext = base64.StdEncoding.EncodeToString(tampered)
+
+			_, err := DecryptSecret(&enc, password)
+			if !errors.Is(err, ErrDecryptionFailed) {
+				t.Fatalf("byte %d (password %q): expected ErrDecryptionFailed, got: %v", i, password, err)
+			}
+		}
+	}
+}
+
+func TestDecryptSecret_TamperedIV(t *testing.T) {
+	encrypted, err := EncryptSecret("Test", "")
+	if err != nil {
+		t.Fatalf("EncryptSecret failed: %v", err)
+	}
+
+	iv, _ := hex.DecodeString(encrypted.IV)
+	iv[0] ^= 0x01
+	encrypted.IV = hex.EncodeToString(iv)
+
+	_, err = DecryptSecret(encrypted, "")
+	if !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("Expected ErrDecryptionFailed, got: %v", err)
+	}
+}
+
+func TestDecryptSecret_TruncatedCiphertext(t *testing.T) {
+	encrypted, err := EncryptSecret("Test", "")
+	if err != nil {
+		t.Fatalf("EncryptSecret failed: %v", err)
+	}
+
+	encrypted.Ciphertext = base64.StdEncoding.EncodeToString([]byte("short"))
+	_, err = DecryptSecret(encrypted, "")
+	if err == nil {
+		t.Error("Expected error with truncated ciphertext")
+	}
+}
+
+func TestVersionFromParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		want   int
+	}{
+		{"missing", map[string]interface{}{"iterations": 10000.0}, VersionCBC},
+		{"nil", nil, VersionCBC},
+		{"json number", map[string]interface{}{"version": 2.0}, VersionGCM},
+		{"int", map[string]interface{}{"version": 1}, VersionCBC},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VersionFromParams(tt.params); got != tt.want {
+				t.Errorf("VersionFromParams() = %d, want %d", got, tt.want)
+			}
+		})
 	}
-	// Error is acceptable too (invalid padding or other decryption error)
 }
 
 func TestEncryptSecret_DifferentPasswordsProduceDifferentCiphertext(t *testing.T) {
@@ -277,3 +437,27 @@ func TestEncryptSecret_Unicode(t *testing.T) {
 		t.Errorf("Unicode should be preserved. Expected: %q, Got: %q", plaintext, decrypted)
 	}
 }
+
+func TestEncryptBytes_Binary(t *testing.T) {
+	// Every byte value, including sequences that are not valid UTF-8
+	data := make([]byte, 512)
+	for i := range data {
+		data[i] = byte(255 - i%256)
+	}
+
+	for _, password := range []string{"", "password"} {
+		encrypted, err := EncryptBytes(data, password, Options{KDF: KDFParams{Name: KDFScrypt, N: 1024, R: 8, P: 1}})
+		if err != nil {
+			t.Fatalf("EncryptBytes failed: %v", err)
+		}
+
+		decrypted, err := DecryptBytes(encrypted, password)
+		if err != nil {
+			t.Fatalf("DecryptBytes failed: %v", err)
+		}
+
+		if !bytes.Equal(decrypted, data) {
+			t.Errorf("Binary data should round-trip unchanged (password %q)", password)
+		}
+	}
+}
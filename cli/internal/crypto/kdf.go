@@ -0,0 +1,245 @@
+package crypto
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Key derivation function names, matching the server's kdf enum.
+const (
+	KDFPBKDF2   = "pbkdf2"
+	KDFArgon2id = "argon2id"
+	KDFScrypt   = "scrypt"
+)
+
+const (
+	// Argon2Time is the default number of Argon2id passes
+	Argon2Time = 3
+	// Argon2Memory is the default Argon2id memory cost in KiB (64 MiB)
+	Argon2Memory = 64 * 1024
+	// Argon2Parallelism is the default number of Argon2id lanes
+	Argon2Parallelism = 4
+	// ScryptN is the default scrypt CPU/memory cost (must be a power of two)
+	ScryptN = 1 << 15
+	// ScryptR is the default scrypt block size
+	ScryptR = 8
+	// ScryptP is the default scrypt parallelization factor
+	ScryptP = 1
+	// PBKDF2SaltedIterations is the default iteration count for salted PBKDF2-SHA256
+	PBKDF2SaltedIterations = 600000
+
+	// Upper bounds keep a hostile server from making redeem burn unbounded CPU or memory.
+	maxArgon2Time   = 64
+	maxArgon2Memory = 4 * 1024 * 1024
+	maxScryptN      = 1 << 22
+	maxScryptRP     = 1 << 10
+	minPBKDF2Iter   = 1000
+	maxPBKDF2Iter   = 10000000
+)
+
+var (
+	// ErrUnsupportedKDF is returned for key derivation functions this client does not implement
+	ErrUnsupportedKDF = errors.New("unsupported key derivation function")
+	// ErrInvalidKDFParams is returned when KDF cost parameters or salt are missing or out of range
+	ErrInvalidKDFParams = errors.New("invalid KDF parameters")
+)
+
+// KDFParams describes how the password-layer key is derived.
+// Only the fields relevant to Name are used.
+//
+// A pbkdf2 KDF has two modes, chosen by the password payload marker:
+//   - "PWD:" is the legacy web scheme: PBKDF2-SHA1, empty salt, PBKDF2Iterations iterations.
+//   - "PWS:" is PBKDF2-SHA256 salted with EncryptedSecret.Salt, using Iterations.
+//
+// Argon2id and scrypt use a random per-secret Salt stored in kdfParams.
+type KDFParams struct {
+	Name string
+
+	// PBKDF2 (salted mode only; the legacy mode always uses PBKDF2Iterations)
+	Iterations int
+
+	// Argon2id
+	Time        uint32
+	Memory      uint32 // KiB
+	Parallelism uint8
+
+	// scrypt
+	N int
+	R int
+	P int
+
+	// Salt is the hex-encoded KDF salt (argon2id and scrypt only)
+	Salt string
+}
+
+// DefaultKDFParams returns recommended cost parameters for the named KDF.
+// The salt is left empty; EncryptSecretWithOptions generates one per secret.
+func DefaultKDFParams(name string) (KDFParams, error) {
+	switch name {
+	case KDFPBKDF2:
+		return KDFParams{Name: KDFPBKDF2}, nil
+	case KDFArgon2id:
+		return KDFParams{
+			Name:        KDFArgon2id,
+			Time:        Argon2Time,
+			Memory:      Argon2Memory,
+			Parallelism: Argon2Parallelism,
+		}, nil
+	case KDFScrypt:
+		return KDFParams{Name: KDFScrypt, N: ScryptN, R: ScryptR, P: ScryptP}, nil
+	}
+	return KDFParams{}, fmt.Errorf("%w: %q", ErrUnsupportedKDF, name)
+}
+
+// Validate checks that the cost parameters are usable and within safe bounds.
+func (p KDFParams) Validate() error {
+	switch p.Name {
+	case KDFPBKDF2:
+		if p.Iterations != 0 && (p.Iterations < minPBKDF2Iter || p.Iterations > maxPBKDF2Iter) {
+			return fmt.Errorf("%w: pbkdf2 iterations must be between %d and %d", ErrInvalidKDFParams, minPBKDF2Iter, maxPBKDF2Iter)
+		}
+	case KDFArgon2id:
+		if p.Time < 1 || p.Time > maxArgon2Time {
+			return fmt.Errorf("%w: argon2id time must be between 1 and %d", ErrInvalidKDFParams, maxArgon2Time)
+		}
+		if p.Parallelism < 1 {
+			return fmt.Errorf("%w: argon2id parallelism must be at least 1", ErrInvalidKDFParams)
+		}
+		if p.Memory < 8*uint32(p.Parallelism) || p.Memory > maxArgon2Memory {
+			return fmt.Errorf("%w: argon2id memory must be between %d and %d KiB", ErrInvalidKDFParams, 8*uint32(p.Parallelism), maxArgon2Memory)
+		}
+	case KDFScrypt:
+		if p.N < 2 || p.N > maxScryptN || p.N&(p.N-1) != 0 {
+			return fmt.Errorf("%w: scrypt N must be a power of two between 2 and %d", ErrInvalidKDFParams, maxScryptN)
+		}
+		if p.R < 1 || p.R > maxScryptRP || p.P < 1 || p.P > maxScryptRP {
+			return fmt.Errorf("%w: scrypt r and p must be between 1 and %d", ErrInvalidKDFParams, maxScryptRP)
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedKDF, p.Name)
+	}
+	return nil
+}
+
+// Map returns the parameters as kdfParams entries for the API request.
+func (p KDFParams) Map() map[string]interface{} {
+	switch p.Name {
+	case KDFArgon2id:
+		return map[string]interface{}{
+			"salt":        p.Salt,
+			"time":        p.Time,
+			"memory":      p.Memory,
+			"parallelism": p.Parallelism,
+		}
+	case KDFScrypt:
+		return map[string]interface{}{
+			"salt": p.Salt,
+			"n":    p.N,
+			"r":    p.R,
+			"p":    p.P,
+		}
+	}
+	iterations := p.Iterations
+	if iterations == 0 {
+		iterations = PBKDF2Iterations
+	}
+	return map[string]interface{}{
+		"iterations": iterations,
+	}
+}
+
+// ParseKDFParams reads the KDF name and kdfParams returned by the server.
+// An empty name is treated as pbkdf2, the only KDF the web interface uses.
+func ParseKDFParams(name string, params map[string]interface{}) (KDFParams, error) {
+	if name == "" {
+		name = KDFPBKDF2
+	}
+
+	p := KDFParams{Name: name}
+	switch name {
+	case KDFPBKDF2:
+		p.Iterations = intParam(params, "iterations")
+		if err := p.Validate(); err != nil {
+			return KDFParams{}, err
+		}
+		return p, nil
+	case KDFArgon2id:
+		time, memory, lanes := intParam(params, "time"), intParam(params, "memory"), intParam(params, "parallelism")
+		if time < 0 || memory < 0 || memory > maxArgon2Memory || lanes < 0 || lanes > 255 {
+			return KDFParams{}, fmt.Errorf("%w: argon2id cost out of range", ErrInvalidKDFParams)
+		}
+		p.Time = uint32(time)
+		p.Memory = uint32(memory)
+		p.Parallelism = uint8(lanes)
+	case KDFScrypt:
+		p.N = intParam(params, "n")
+		p.R = intParam(params, "r")
+		p.P = intParam(params, "p")
+	default:
+		return KDFParams{}, fmt.Errorf("%w: %q", ErrUnsupportedKDF, name)
+	}
+
+	p.Salt, _ = params["salt"].(string)
+	if p.Salt == "" {
+		return KDFParams{}, fmt.Errorf("%w: missing salt", ErrInvalidKDFParams)
+	}
+	if err := p.Validate(); err != nil {
+		return KDFParams{}, err
+	}
+	return p, nil
+}
+
+// deriveKey derives the password-layer key for "PWD:" payloads.
+func (p KDFParams) deriveKey(password string) ([]byte, error) {
+	if p.Name == "" || p.Name == KDFPBKDF2 {
+		// Use SHA1 for PBKDF2 to match CryptoJS default (web implementation)
+		return pbkdf2.Key([]byte(password), nil, PBKDF2Iterations, KeySize, sha1.New), nil
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	salt, err := hex.DecodeString(p.Salt)
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("%w: bad salt", ErrInvalidKDFParams)
+	}
+
+	if p.Name == KDFArgon2id {
+		return argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Parallelism, KeySize), nil
+	}
+	return scrypt.Key([]byte(password), salt, p.N, p.R, p.P, KeySize)
+}
+
+// deriveSaltedKey derives the password-layer key for "PWS:" payloads using
+// PBKDF2-SHA256 with the per-secret salt and the recorded iteration count.
+func (p KDFParams) deriveSaltedKey(password string, salt []byte) ([]byte, error) {
+	if len(salt) == 0 {
+		return nil, fmt.Errorf("%w: missing salt", ErrInvalidKDFParams)
+	}
+	if p.Iterations == 0 {
+		return nil, fmt.Errorf("%w: missing iterations", ErrInvalidKDFParams)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return pbkdf2.Key([]byte(password), salt, p.Iterations, KeySize, sha256.New), nil
+}
+
+// intParam reads an integer from decoded JSON, where numbers arrive as float64.
+func intParam(params map[string]interface{}, key string) int {
+	switch v := params[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
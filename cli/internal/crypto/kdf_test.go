@@ -0,0 +1,336 @@
+package crypto
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// cheapKDFs keeps the tests fast while exercising every KDF.
+var cheapKDFs = []KDFParams{
+	{Name: KDFPBKDF2, Iterations: 1000},
+	{Name: KDFArgon2id, Time: 1, Memory: 64, Parallelism: 1},
+	{Name: KDFScrypt, N: 1024, R: 8, P: 1},
+}
+
+func TestEncryptSecret_KDFRoundTrip(t *testing.T) {
+	for _, kdf := range cheapKDFs {
+		for _, version := range []int{VersionCBC, VersionGCM} {
+			encrypted, err := EncryptSecretWithOptions("kdf secret", "hunter2", Options{Version: version, KDF: kdf})
+			if err != nil {
+				t.Fatalf("%s/v%d: EncryptSecretWithOptions failed: %v", kdf.Name, version, err)
+			}
+
+			if encrypted.KDF.Name != kdf.Name {
+				t.Errorf("%s/v%d: expected KDF %q, got %q", kdf.Name, version, kdf.Name, encrypted.KDF.Name)
+			}
+
+			decrypted, err := DecryptSecret(encrypted, "hunter2")
+			if err != nil {
+				t.Fatalf("%s/v%d: DecryptSecret failed: %v", kdf.Name, version, err)
+			}
+			if decrypted != "kdf secret" {
+				t.Errorf("%s/v%d: expected %q, got %q", kdf.Name, version, "kdf secret", decrypted)
+			}
+		}
+	}
+}
+
+func TestEncryptSecret_KDFWrongPassword(t *testing.T) {
+	for _, kdf := range cheapKDFs {
+		encrypted, err := EncryptSecretWithOptions("kdf secret", "hunter2", Options{KDF: kdf})
+		if err != nil {
+			t.Fatalf("%s: EncryptSecretWithOptions failed: %v", kdf.Name, err)
+		}
+
+		_, err = DecryptSecret(encrypted, "hunter3")
+		if !errors.Is(err, ErrDecryptionFailed) {
+			t.Errorf("%s: expected ErrDecryptionFailed, got: %v", kdf.Name, err)
+		}
+	}
+}
+
+func TestEncryptSecret_KDFSaltPerSecret(t *testing.T) {
+	kdf := cheapKDFs[1]
+	enc1, err := EncryptSecretWithOptions("a", "pw", Options{KDF: kdf})
+	if err != nil {
+		t.Fatalf("EncryptSecretWithOptions failed: %v", err)
+	}
+	enc2, err := EncryptSecretWithOptions("a", "pw", Options{KDF: kdf})
+	if err != nil {
+		t.Fatalf("EncryptSecretWithOptions failed: %v", err)
+	}
+
+	if enc1.KDF.Salt == "" || enc1.KDF.Salt == enc2.KDF.Salt {
+		t.Errorf("Each secret should get a fresh KDF salt, got %q and %q", enc1.KDF.Salt, enc2.KDF.Salt)
+	}
+}
+
+func TestEncryptSecret_DefaultKDF(t *testing.T) {
+	gcm, err := EncryptSecret("a", "pw")
+	if err != nil {
+		t.Fatalf("EncryptSecret failed: %v", err)
+	}
+	if gcm.KDF.Name != KDFArgon2id {
+		t.Errorf("GCM default KDF should be argon2id, got %q", gcm.KDF.Name)
+	}
+
+	cbc, err := EncryptSecretWithOptions("a", "pw", Options{Version: VersionCBC})
+	if err != nil {
+		t.Fatalf("EncryptSecretWithOptions failed: %v", err)
+	}
+	if cbc.KDF.Name != KDFPBKDF2 || cbc.KDF.Salt != "" {
+		t.Errorf("CBC default KDF should be unsalted pbkdf2, got %+v", cbc.KDF)
+	}
+
+	plain, err := EncryptSecretWithOptions("a", "", Options{KDF: cheapKDFs[1]})
+	if err != nil {
+		t.Fatalf("EncryptSecretWithOptions failed: %v", err)
+	}
+	if plain.KDF.Name != KDFPBKDF2 || plain.KDF.Salt != "" {
+		t.Errorf("Secrets without a password should not carry KDF parameters, got %+v", plain.KDF)
+	}
+}
+
+func TestParseKDFParams_RoundTrip(t *testing.T) {
+	for _, kdf := range cheapKDFs {
+		encrypted, err := EncryptSecretWithOptions("through the server", "pw", Options{KDF: kdf})
+		if err != nil {
+			t.Fatalf("%s: EncryptSecretWithOptions failed: %v", kdf.Name, err)
+		}
+
+		// Simulate the server storing and returning kdfParams as JSON
+		raw, err := json.Marshal(encrypted.KDF.Map())
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		var params map[string]interface{}
+		if err := json.Unmarshal(raw, &params); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		parsed, err := ParseKDFParams(kdf.Name, params)
+		if err != nil {
+			t.Fatalf("%s: ParseKDFParams failed: %v", kdf.Name, err)
+		}
+		if parsed != encrypted.KDF {
+			t.Errorf("%s: expected %+v, got %+v", kdf.Name, encrypted.KDF, parsed)
+		}
+
+		encrypted.KDF = parsed
+		if _, err := DecryptSecret(encrypted, "pw"); err != nil {
+			t.Errorf("%s: DecryptSecret failed: %v", kdf.Name, err)
+		}
+	}
+}
+
+func TestParseKDFParams_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		kdf    string
+		params map[string]interface{}
+		want   error
+	}{
+		{"unknown kdf", "bcrypt", nil, ErrUnsupportedKDF},
+		{"argon2id missing salt", KDFArgon2id, map[string]interface{}{"time": 1.0, "memory": 64.0, "parallelism": 1.0}, ErrInvalidKDFParams},
+		{"argon2id zero time", KDFArgon2id, map[string]interface{}{"salt": "00ff", "time": 0.0, "memory": 64.0, "parallelism": 1.0}, ErrInvalidKDFParams},
+		{"argon2id huge memory", KDFArgon2id, map[string]interface{}{"salt": "00ff", "time": 1.0, "memory": 1e12, "parallelism": 1.0}, ErrInvalidKDFParams},
+		{"argon2id lanes overflow", KDFArgon2id, map[string]interface{}{"salt": "00ff", "time": 1.0, "memory": 4096.0, "parallelism": 257.0}, ErrInvalidKDFParams},
+		{"scrypt N not power of two", KDFScrypt, map[string]interface{}{"salt": "00ff", "n": 1000.0, "r": 8.0, "p": 1.0}, ErrInvalidKDFParams},
+		{"scrypt N too large", KDFScrypt, map[string]interface{}{"salt": "00ff", "n": float64(1 << 30), "r": 8.0, "p": 1.0}, ErrInvalidKDFParams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseKDFParams(tt.kdf, tt.params)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got: %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestParseKDFParams_LegacyWeb(t *testing.T) {
+	// The web interface sends kdf "pbkdf2" with only iterations and isPasswordProtected
+	params := map[string]interface{}{"iterations": 10000.0, "isPasswordProtected": true}
+
+	kdf, err := ParseKDFParams(KDFPBKDF2, params)
+	if err != nil {
+		t.Fatalf("ParseKDFParams failed: %v", err)
+	}
+
+	encrypted, err := EncryptSecretWithOptions("from the browser", "pw", Options{Version: VersionCBC})
+	if err != nil {
+		t.Fatalf("EncryptSecretWithOptions failed: %v", err)
+	}
+
+	enc := &EncryptedSecret{
+		Ciphertext: encrypted.Ciphertext,
+		IV:         encrypted.IV,
+		Salt:       encrypted.Salt,
+		Key:        encrypted.Key,
+		Version:    VersionFromParams(params),
+		KDF:        kdf,
+	}
+
+	decrypted, err := DecryptSecret(enc, "pw")
+	if err != nil {
+		t.Fatalf("DecryptSecret failed: %v", err)
+	}
+	if decrypted != "from the browser" {
+		t.Errorf("expected %q, got %q", "from the browser", decrypted)
+	}
+}
+
+// outerPayload decrypts only the outer layer so tests can inspect the password payload marker.
+func outerPayload(t *testing.T, enc *EncryptedSecret) string {
+	t.Helper()
+	key, _ := hex.DecodeString(enc.Key)
+	iv, _ := hex.DecodeString(enc.IV)
+	ciphertext, _ := base64.StdEncoding.DecodeString(enc.Ciphertext)
+	payload, err := open(enc.Version, ciphertext, key, iv)
+	if err != nil {
+		t.Fatalf("decrypt outer layer: %v", err)
+	}
+	return string(payload)
+}
+
+// sealUnsaltedPayload builds a secret whose password layer uses the legacy
+// unsalted "PWD:" scheme, as produced by the web interface and earlier CLI releases.
+func sealUnsaltedPayload(t *testing.T, plaintext, password string, version int) *EncryptedSecret {
+	t.Helper()
+	passwordIV, _ := randomBytes(ivSize(version))
+	passwordKey := pbkdf2.Key([]byte(password), nil, PBKDF2Iterations, KeySize, sha1.New)
+	inner, err := seal(version, []byte(plaintext), passwordKey, passwordIV)
+	if err != nil {
+		t.Fatalf("seal inner: %v", err)
+	}
+	payload := passwordPrefix + base64.StdEncoding.EncodeToString(inner) + "||" + hex.EncodeToString(passwordIV)
+
+	outerKey, _ := randomBytes(KeySize)
+	outerIV, _ := randomBytes(ivSize(version))
+	salt, _ := randomBytes(SaltSize)
+	outer, err := seal(version, []byte(payload), outerKey, outerIV)
+	if err != nil {
+		t.Fatalf("seal outer: %v", err)
+	}
+
+	return &EncryptedSecret{
+		Ciphertext: base64.StdEncoding.EncodeToString(outer),
+		IV:         hex.EncodeToString(outerIV),
+		Salt:       hex.EncodeToString(salt),
+		Key:        hex.EncodeToString(outerKey),
+		Version:    version,
+		KDF:        KDFParams{Name: KDFPBKDF2},
+	}
+}
+
+func TestSaltedPBKDF2_RoundTrip(t *testing.T) {
+	kdf := KDFParams{Name: KDFPBKDF2, Iterations: 2000}
+	encrypted, err := EncryptSecretWithOptions("salted", "pw", Options{KDF: kdf})
+	if err != nil {
+		t.Fatalf("EncryptSecretWithOptions failed: %v", err)
+	}
+
+	if !strings.HasPrefix(outerPayload(t, encrypted), saltedPasswordPrefix) {
+		t.Errorf("Expected %q marker in password payload", saltedPasswordPrefix)
+	}
+	if got := encrypted.KDF.Map()["iterations"]; got != 2000 {
+		t.Errorf("kdfParams.iterations should record the salted iteration count, got %v", got)
+	}
+
+	decrypted, err := DecryptSecret(encrypted, "pw")
+	if err != nil {
+		t.Fatalf("DecryptSecret failed: %v", err)
+	}
+	if decrypted != "salted" {
+		t.Errorf("expected %q, got %q", "salted", decrypted)
+	}
+}
+
+func TestSaltedPBKDF2_DefaultIterations(t *testing.T) {
+	encrypted, err := EncryptSecretWithOptions("salted", "pw", Options{KDF: KDFParams{Name: KDFPBKDF2}})
+	if err != nil {
+		t.Fatalf("EncryptSecretWithOptions failed: %v", err)
+	}
+	if encrypted.KDF.Iterations != PBKDF2SaltedIterations {
+		t.Errorf("expected %d iterations, got %d", PBKDF2SaltedIterations, encrypted.KDF.Iterations)
+	}
+}
+
+func TestSaltedPBKDF2_UsesSaltAndIterations(t *testing.T) {
+	kdf := KDFParams{Name: KDFPBKDF2, Iterations: 2000}
+	encrypted, err := EncryptSecretWithOptions("salted", "pw", Options{KDF: kdf})
+	if err != nil {
+		t.Fatalf("EncryptSecretWithOptions failed: %v", err)
+	}
+
+	// A different salt must derive a different key
+	other, err := EncryptSecretWithOptions("salted", "pw", Options{KDF: kdf})
+	if err != nil {
+		t.Fatalf("EncryptSecretWithOptions failed: %v", err)
+	}
+	wrongSalt := *encrypted
+	wrongSalt.Salt = other.Salt
+	if _, err := DecryptSecret(&wrongSalt, "pw"); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("Wrong salt: expected ErrDecryptionFailed, got: %v", err)
+	}
+
+	// The iteration count comes from kdfParams, not a constant
+	wrongIterations := *encrypted
+	wrongIterations.KDF.Iterations = 3000
+	if _, err := DecryptSecret(&wrongIterations, "pw"); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("Wrong iterations: expected ErrDecryptionFailed, got: %v", err)
+	}
+
+	missingIterations := *encrypted
+	missingIterations.KDF.Iterations = 0
+	if _, err := DecryptSecret(&missingIterations, "pw"); !errors.Is(err, ErrInvalidKDFParams) {
+		t.Errorf("Missing iterations: expected ErrInvalidKDFParams, got: %v", err)
+	}
+}
+
+func TestSaltedPBKDF2_LegacyCBCStaysUnsalted(t *testing.T) {
+	encrypted, err := EncryptSecretWithOptions("web", "pw", Options{Version: VersionCBC, KDF: KDFParams{Name: KDFPBKDF2, Iterations: 2000}})
+	if err != nil {
+		t.Fatalf("EncryptSecretWithOptions failed: %v", err)
+	}
+
+	if !strings.HasPrefix(outerPayload(t, encrypted), passwordPrefix) {
+		t.Errorf("Legacy format must keep the %q marker", passwordPrefix)
+	}
+	if encrypted.KDF.Iterations != 0 {
+		t.Errorf("Legacy format ignores iterations, got %d", encrypted.KDF.Iterations)
+	}
+}
+
+func TestSaltedPBKDF2_UnsaltedCompatibility(t *testing.T) {
+	for _, version := range []int{VersionCBC, VersionGCM} {
+		enc := sealUnsaltedPayload(t, "old secret", "pw", version)
+
+		// The server reports iterations for every pbkdf2 secret; the unsalted marker must ignore it
+		kdf, err := ParseKDFParams(KDFPBKDF2, map[string]interface{}{"iterations": 10000.0})
+		if err != nil {
+			t.Fatalf("ParseKDFParams failed: %v", err)
+		}
+		enc.KDF = kdf
+
+		decrypted, err := DecryptSecret(enc, "pw")
+		if err != nil {
+			t.Fatalf("v%d: DecryptSecret failed: %v", version, err)
+		}
+		if decrypted != "old secret" {
+			t.Errorf("v%d: expected %q, got %q", version, "old secret", decrypted)
+		}
+
+		if _, err := DecryptSecret(enc, ""); err != ErrPasswordRequired {
+			t.Errorf("v%d: expected ErrPasswordRequired, got: %v", version, err)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package history keeps an opt-in local ledger of created secrets so they can be listed and revoked later.
+//
+// Entries never contain the encryption key: a ledger entry is enough to delete a secret, not to read it.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/brentdalling/ots-cli/internal/config"
+)
+
+const (
+	// FileName is the ledger file name inside the config directory
+	FileName = "history.json"
+	// Retention is how long an entry is kept after it expires, so `ots list` can still show it as expired
+	Retention = 24 * time.Hour
+)
+
+// Entry records one created secret.
+type Entry struct {
+	ID     string `json:"id"`
+	Server string `json:"server"`
+	// Label is an optional note to tell entries apart
+	Label     string     `json:"label,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// MaxReads is the read limit reported by the server at creation
+	MaxReads int `json:"maxReads"`
+	// Parts lists the chunk secret IDs of a multi-part secret, so revoking it removes every part
+	Parts []string `json:"parts,omitempty"`
+}
+
+// Expired reports whether the entry's expiry has passed at now.
+func (e Entry) Expired(now time.Time) bool {
+	return e.ExpiresAt != nil && !now.Before(*e.ExpiresAt)
+}
+
+// State describes the entry as "pending" or "expired" at now.
+// Reads are not tracked: the server cannot report them without consuming the secret.
+func (e Entry) State(now time.Time) string {
+	if e.Expired(now) {
+		return "expired"
+	}
+	return "pending"
+}
+
+// Store is a ledger file on disk.
+type Store struct {
+	Path string
+}
+
+// Open returns the store at path. The file is created on the first write.
+func Open(path string) *Store {
+	return &Store{Path: path}
+}
+
+// Default opens the ledger in the CLI config directory.
+func Default() (*Store, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, fmt.Errorf("locate config directory: %w", err)
+	}
+	return Open(filepath.Join(dir, FileName)), nil
+}
+
+// Load reads all entries. A missing ledger is empty.
+func (s *Store) Load() ([]Entry, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read history: %w", err)
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse history %s: %w", s.Path, err)
+	}
+	return entries, nil
+}
+
+// Save replaces the ledger with entries. The file is written atomically and readable only by the owner.
+func (s *Store) Save(entries []Entry) error {
+	if entries == nil {
+		entries = []Entry{}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.Path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create history directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, FileName+".*")
+	if err != nil {
+		return fmt.Errorf("write history: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.Path); err != nil {
+		return fmt.Errorf("write history: %w", err)
+	}
+	return nil
+}
+
+// Add records a new entry, pruning stale ones at the same time.
+func (s *Store) Add(entry Entry) error {
+	entries, err := s.Load()
+	if err != nil {
+		return err
+	}
+	return s.Save(append(Prune(entries, time.Now()), entry))
+}
+
+// Remove deletes the entries with the given IDs.
+func (s *Store) Remove(ids ...string) error {
+	entries, err := s.Load()
+	if err != nil {
+		return err
+	}
+
+	drop := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		drop[id] = true
+	}
+
+	kept := entries[:0]
+	for _, entry := range entries {
+		if !drop[entry.ID] {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == len(entries) {
+		return nil
+	}
+	return s.Save(kept)
+}
+
+// Prune loads the ledger, drops stale entries and saves it if anything changed.
+// It returns the remaining entries.
+func (s *Store) Prune() ([]Entry, error) {
+	entries, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	kept := Prune(entries, time.Now())
+	if len(kept) != len(entries) {
+		if err := s.Save(kept); err != nil {
+			return nil, err
+		}
+	}
+	return kept, nil
+}
+
+// Prune returns the entries that have not been expired for longer than Retention at now.
+// Entries without an expiry are kept.
+func Prune(entries []Entry, now time.Time) []Entry {
+	var kept []Entry
+	for _, entry := range entries {
+		if entry.ExpiresAt == nil || now.Before(entry.ExpiresAt.Add(Retention)) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// Find returns the entry with the given ID.
+func Find(entries []Entry, id string) (Entry, bool) {
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
@@ -0,0 +1,107 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func at(t time.Time) *time.Time { return &t }
+
+func TestStore_AddLoadRemove(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "ots", FileName))
+
+	entries, err := s.Load()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Load() on missing file = %v, %v; want empty", entries, err)
+	}
+
+	future := time.Now().Add(time.Hour).Truncate(time.Second)
+	for _, id := range []string{"a", "b", "c"} {
+		if err := s.Add(Entry{ID: id, Server: "http://localhost:3000", ExpiresAt: &future, MaxReads: 1}); err != nil {
+			t.Fatalf("Add(%s) failed: %v", id, err)
+		}
+	}
+
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("history mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if err := s.Remove("b", "missing"); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+
+	entries, err = s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].ID != "a" || entries[1].ID != "c" {
+		t.Fatalf("entries = %+v, want a and c", entries)
+	}
+	if !entries[0].ExpiresAt.Equal(future) {
+		t.Errorf("ExpiresAt = %v, want %v", entries[0].ExpiresAt, future)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Now()
+	entries := []Entry{
+		{ID: "pending", ExpiresAt: at(now.Add(time.Hour))},
+		{ID: "just-expired", ExpiresAt: at(now.Add(-time.Hour))},
+		{ID: "stale", ExpiresAt: at(now.Add(-Retention - time.Minute))},
+		{ID: "no-expiry"},
+	}
+
+	kept := Prune(entries, now)
+	if len(kept) != 3 {
+		t.Fatalf("Prune() kept %d entries, want 3: %+v", len(kept), kept)
+	}
+	if _, ok := Find(kept, "stale"); ok {
+		t.Error("stale entry was not pruned")
+	}
+
+	if e, _ := Find(kept, "just-expired"); !e.Expired(now) {
+		t.Error("just-expired entry should report Expired")
+	}
+	if e, _ := Find(kept, "pending"); e.Expired(now) {
+		t.Error("pending entry should not report Expired")
+	}
+	if e, _ := Find(kept, "no-expiry"); e.Expired(now) {
+		t.Error("entry without expiry should never report Expired")
+	}
+}
+
+func TestStore_AddPrunes(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), FileName))
+	stale := time.Now().Add(-Retention - time.Hour)
+	if err := s.Save([]Entry{{ID: "old", ExpiresAt: &stale}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Add(Entry{ID: "new"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != "new" {
+		t.Errorf("entries = %+v, want only new", entries)
+	}
+}
+
+func TestStore_LoadCorrupt(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), FileName))
+	if err := os.WriteFile(s.Path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(); err == nil {
+		t.Error("Load() of corrupt file should fail")
+	}
+}
@@ -0,0 +1,111 @@
+package link
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+
+	"github.com/brentdalling/ots-cli/internal/passgen"
+)
+
+// keySize is the length of a decryption key in bytes.
+const keySize = 32
+
+// KeyWordCount is the number of words in a key's word code: 20 words for the
+// 256-bit key, followed by one checksum word.
+const KeyWordCount = 21
+
+// keyDigits is the number of words encoding the key itself.
+const keyDigits = KeyWordCount - 1
+
+// wordIndex maps each word of the word list to its position.
+var wordIndex = func() map[string]int {
+	m := make(map[string]int, len(passgen.Wordlist))
+	for i, w := range passgen.Wordlist {
+		m[w] = i
+	}
+	return m
+}()
+
+// KeyWords renders a hex-encoded key as a word code that is easier to read out or type than hex,
+// e.g. "lantern-mosaic-...". Each word stands for a base-7776 digit of the key; the last word
+// is a checksum, so ParseKey catches mistyped words before the key is used.
+func KeyWords(key string) (string, error) {
+	raw, err := decodeHexKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	base := big.NewInt(int64(len(passgen.Wordlist)))
+	n := new(big.Int).SetBytes(raw)
+	digit := new(big.Int)
+	words := make([]string, KeyWordCount)
+	for i := keyDigits - 1; i >= 0; i-- {
+		n.DivMod(n, base, digit)
+		words[i] = passgen.Wordlist[digit.Int64()]
+	}
+	words[keyDigits] = passgen.Wordlist[checksum(raw)]
+	return strings.Join(words, "-"), nil
+}
+
+// ParseKey accepts a key as hex, as in a link, or as a word code from KeyWords,
+// and returns it hex-encoded. Word codes may be separated by hyphens or spaces, in any case.
+func ParseKey(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", fmt.Errorf("empty key")
+	}
+	// No word code is a single word, so a run of hex digits is meant as hex
+	if strings.Trim(s, "0123456789abcdefABCDEF") == "" {
+		if _, err := decodeHexKey(s); err != nil {
+			return "", err
+		}
+		return strings.ToLower(s), nil
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r == '-' || r == ',' || r == '.' || unicode.IsSpace(r)
+	})
+	if len(words) != KeyWordCount {
+		return "", fmt.Errorf("invalid key: expected %d hex characters or %d words, got %d words", 2*keySize, KeyWordCount, len(words))
+	}
+
+	base := big.NewInt(int64(len(passgen.Wordlist)))
+	n := new(big.Int)
+	for i, w := range words {
+		digit, ok := wordIndex[w]
+		if !ok {
+			return "", fmt.Errorf("invalid key: word %d, %q, is not in the word list", i+1, w)
+		}
+		if i < keyDigits {
+			n.Mul(n, base).Add(n, big.NewInt(int64(digit)))
+		}
+	}
+	if n.BitLen() > 8*keySize {
+		return "", fmt.Errorf("invalid key: the words do not form a key; check them for typos")
+	}
+	raw := n.FillBytes(make([]byte, keySize))
+	if wordIndex[words[keyDigits]] != checksum(raw) {
+		return "", fmt.Errorf("invalid key: the checksum word does not match; check the words for typos")
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// decodeHexKey decodes a hex-encoded key and checks its length.
+func decodeHexKey(key string) ([]byte, error) {
+	raw, err := hex.DecodeString(key)
+	if err != nil || len(raw) != keySize {
+		return nil, fmt.Errorf("invalid key: expected %d hex characters", 2*keySize)
+	}
+	return raw, nil
+}
+
+// checksum returns the position of the checksum word of a key.
+func checksum(raw []byte) int {
+	sum := sha256.Sum256(raw)
+	return int(binary.BigEndian.Uint16(sum[:2])) % len(passgen.Wordlist)
+}
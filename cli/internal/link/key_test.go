@@ -0,0 +1,87 @@
+package link
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/brentdalling/ots-cli/internal/passgen"
+)
+
+const testKey = "1463a65fae9e2347a102bc13605f7358d161e9a7c6b952b38e60015290aba6f2"
+
+func TestKeyWords_RoundTrip(t *testing.T) {
+	keys := []string{
+		testKey,
+		strings.Repeat("00", keySize),
+		strings.Repeat("ff", keySize),
+		"00000000000000000000000000000000000000000000000000000000000000a1",
+	}
+
+	for _, key := range keys {
+		code, err := KeyWords(key)
+		if err != nil {
+			t.Fatalf("KeyWords(%s) failed: %v", key, err)
+		}
+		if n := len(strings.Split(code, "-")); n != KeyWordCount {
+			t.Errorf("KeyWords(%s) has %d words, want %d", key, n, KeyWordCount)
+		}
+		got, err := ParseKey(code)
+		if err != nil {
+			t.Fatalf("ParseKey(%q) failed: %v", code, err)
+		}
+		if got != key {
+			t.Errorf("ParseKey(KeyWords(%s)) = %s", key, got)
+		}
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	code, err := KeyWords(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := strings.Split(code, "-")
+	swapped := append([]string{words[1], words[0]}, words[2:]...)
+	unknown := append([]string{"notaword"}, words[1:]...)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"hex", testKey, testKey, false},
+		{"upper-case hex", strings.ToUpper(testKey), testKey, false},
+		{"hex with whitespace", " " + testKey + "\n", testKey, false},
+		{"word code", code, testKey, false},
+		{"spaces and capitals", strings.ToUpper(strings.Join(words, " ")), testKey, false},
+		{"one word per line", strings.Join(words, "\n"), testKey, false},
+		{"empty", "  ", "", true},
+		{"short hex", testKey[:62], "", true},
+		{"odd-length hex", testKey[:63], "", true},
+		{"too few words", strings.Join(words[1:], "-"), "", true},
+		{"unknown word", strings.Join(unknown, "-"), "", true},
+		{"swapped words", strings.Join(swapped, "-"), "", true},
+		{"overflow", strings.Repeat(passgen.Wordlist[len(passgen.Wordlist)-1]+"-", KeyWordCount-1) + words[keyDigits], "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKey(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyWords_Invalid(t *testing.T) {
+	for _, key := range []string{"", "zz", testKey[:60]} {
+		if _, err := KeyWords(key); err == nil {
+			t.Errorf("KeyWords(%q) should fail", key)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Package link parses the share links printed by `ots create`.
+package link
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Link is a parsed share link.
+type Link struct {
+	// Server is the scheme and host the link points at, followed by the path prefix of a
+	// deployment under a sub-path, or empty for a bare ID
+	Server string
+	// ID is the server-generated secret identifier
+	ID string
+	// Key is the hex-encoded decryption key, or empty if the link has none
+	Key string
+	// KeyInFragment places the key in the URL fragment, which browsers never send to the server
+	KeyInFragment bool
+}
+
+// errFormat is returned for links in none of the accepted forms.
+var errFormat = fmt.Errorf("invalid link format: expected {server}/s/{id}?key={key} or {server}/redeem?id={id}&key={key}")
+
+// Parse parses a full share link. Accepted forms:
+//
+//	{server}/s/{id}?key={key}
+//	{server}/s/{id}#key={key}
+//	{server}/redeem?id={id}&key={key}, the web page /s/{id} redirects browsers to
+//
+// The server may include a path prefix, e.g. https://example.com/ots, or be unix://{socket path}.
+// id and key may be given in either the query or the fragment.
+// The key is optional here; callers that need it check Link.Key.
+func Parse(raw string) (*Link, error) {
+	parsedURL, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	query := parsedURL.Query()
+	fragment, err := url.ParseQuery(parsedURL.EscapedFragment())
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL fragment: %w", err)
+	}
+
+	// The prefix is what precedes /s/{id} or /redeem; in a unix:// link it is the socket path
+	segments := strings.Split(strings.TrimSuffix(parsedURL.Path, "/"), "/")
+	var prefix, id string
+	switch n := len(segments); {
+	case n >= 2 && segments[n-1] == "redeem":
+		prefix = strings.Join(segments[:n-1], "/")
+		id = query.Get("id")
+		if id == "" {
+			id = fragment.Get("id")
+		}
+	case n >= 2 && segments[n-2] == "s":
+		prefix = strings.Join(segments[:n-2], "/")
+		id = segments[n-1]
+	case n >= 2 && segments[n-1] == "s":
+		return nil, fmt.Errorf("missing token in URL")
+	default:
+		return nil, errFormat
+	}
+	if id == "" {
+		return nil, fmt.Errorf("missing token in URL")
+	}
+	if !ValidID(id) {
+		return nil, fmt.Errorf("invalid secret ID %q in URL", id)
+	}
+
+	l := &Link{ID: id, Key: query.Get("key")}
+	if l.Key == "" {
+		l.Key = fragment.Get("key")
+		l.KeyInFragment = l.Key != ""
+	}
+	switch {
+	case parsedURL.Scheme == "unix":
+		if prefix == "" {
+			return nil, errFormat
+		}
+		l.Server = "unix://" + prefix
+	case parsedURL.Scheme != "" && parsedURL.Host != "":
+		l.Server = fmt.Sprintf("%s://%s%s", parsedURL.Scheme, parsedURL.Host, prefix)
+	}
+	return l, nil
+}
+
+// ParseLinkOrID accepts either a share link or a bare secret ID.
+func ParseLinkOrID(raw string) (*Link, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, fmt.Errorf("empty link or ID")
+	}
+	if !strings.ContainsAny(raw, "/?#:") {
+		if !ValidID(raw) {
+			return nil, fmt.Errorf("invalid secret ID %q", raw)
+		}
+		return &Link{ID: raw}, nil
+	}
+	return Parse(raw)
+}
+
+// ValidID reports whether id can be a secret ID. The server's IDs are ULIDs, which are
+// letters and digits, so an ID with anything else cannot name a secret, and in a
+// request path could name something else.
+func ValidID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the link as printed by `ots create`: {server}/s/{id}?key={key},
+// or {server}/s/{id}#key={key} with KeyInFragment. Without a key, it is the bare {server}/s/{id}.
+func (l *Link) String() string {
+	if l.Key == "" {
+		return fmt.Sprintf("%s/s/%s", l.Server, l.ID)
+	}
+	if l.KeyInFragment {
+		return fmt.Sprintf("%s/s/%s#key=%s", l.Server, l.ID, l.Key)
+	}
+	return fmt.Sprintf("%s/s/%s?key=%s", l.Server, l.ID, l.Key)
+}
@@ -0,0 +1,109 @@
+package link
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    Link
+		wantErr bool
+	}{
+		{"full link", "https://ots.example.com/s/01ABC?key=deadbeef", Link{Server: "https://ots.example.com", ID: "01ABC", Key: "deadbeef"}, false},
+		{"port", "http://localhost:3000/s/01ABC?key=ff", Link{Server: "http://localhost:3000", ID: "01ABC", Key: "ff"}, false},
+		{"no key", "https://ots.example.com/s/01ABC", Link{Server: "https://ots.example.com", ID: "01ABC"}, false},
+		{"path only", "/s/01ABC?key=ff", Link{ID: "01ABC", Key: "ff"}, false},
+		{"unix socket", "unix:///run/ots/ots.sock/s/01ABC?key=ff", Link{Server: "unix:///run/ots/ots.sock", ID: "01ABC", Key: "ff"}, false},
+		{"unix socket without token", "unix:///run/ots.sock", Link{}, true},
+		{"wrong path", "https://ots.example.com/x/01ABC?key=ff", Link{}, true},
+		{"missing id", "https://ots.example.com/s/?key=ff", Link{}, true},
+		{"key in fragment", "https://ots.example.com/s/01ABC#key=deadbeef", Link{Server: "https://ots.example.com", ID: "01ABC", Key: "deadbeef", KeyInFragment: true}, false},
+		{"query key wins over fragment", "https://ots.example.com/s/01ABC?key=aa#key=bb", Link{Server: "https://ots.example.com", ID: "01ABC", Key: "aa"}, false},
+		{"empty fragment", "https://ots.example.com/s/01ABC#", Link{Server: "https://ots.example.com", ID: "01ABC"}, false},
+		{"trailing slash", "https://ots.example.com/s/01ABC/?key=ff", Link{Server: "https://ots.example.com", ID: "01ABC", Key: "ff"}, false},
+		{"surrounding whitespace", "  https://ots.example.com/s/01ABC?key=ff\n", Link{Server: "https://ots.example.com", ID: "01ABC", Key: "ff"}, false},
+		{"path prefix", "https://example.com/tools/ots/s/01ABC?key=ff", Link{Server: "https://example.com/tools/ots", ID: "01ABC", Key: "ff"}, false},
+		{"path prefix with fragment", "https://example.com/ots/s/01ABC#key=ff", Link{Server: "https://example.com/ots", ID: "01ABC", Key: "ff", KeyInFragment: true}, false},
+		{"web redeem page", "https://ots.example.com/redeem?id=01ABC&key=deadbeef", Link{Server: "https://ots.example.com", ID: "01ABC", Key: "deadbeef"}, false},
+		{"web redeem page with fragment", "https://ots.example.com/redeem?id=01ABC#key=ff", Link{Server: "https://ots.example.com", ID: "01ABC", Key: "ff", KeyInFragment: true}, false},
+		{"web redeem page id in fragment", "https://ots.example.com/redeem#id=01ABC&key=ff", Link{Server: "https://ots.example.com", ID: "01ABC", Key: "ff", KeyInFragment: true}, false},
+		{"web redeem page with prefix", "https://example.com/ots/redeem?id=01ABC&key=ff", Link{Server: "https://example.com/ots", ID: "01ABC", Key: "ff"}, false},
+		{"web redeem page path only", "/redeem?id=01ABC&key=ff", Link{ID: "01ABC", Key: "ff"}, false},
+		{"web redeem page without id", "https://ots.example.com/redeem?key=ff", Link{}, true},
+		{"unix socket with fragment", "unix:///run/ots.sock/s/01ABC#key=ff", Link{Server: "unix:///run/ots.sock", ID: "01ABC", Key: "ff", KeyInFragment: true}, false},
+		{"unix socket redeem page", "unix:///run/ots.sock/redeem?id=01ABC&key=ff", Link{Server: "unix:///run/ots.sock", ID: "01ABC", Key: "ff"}, false},
+		{"unix socket without path", "unix:///s/01ABC?key=ff", Link{}, true},
+		{"bad fragment escape", "https://ots.example.com/s/01ABC#key=%zz", Link{}, true},
+		{"root", "https://ots.example.com/", Link{}, true},
+		{"escaped path in id", "https://ots.example.com/redeem?id=..%2F..%2Fadmin&key=ff", Link{}, true},
+		{"escaped query in id", "https://ots.example.com/redeem?id=x%3Fy&key=ff", Link{}, true},
+		{"escaped path in fragment id", "https://ots.example.com/redeem#id=..%2Fadmin&key=ff", Link{}, true},
+		{"escaped slash in path", "https://ots.example.com/s/..%2Fadmin?key=ff", Link{}, true},
+		{"dot-dot id", "https://ots.example.com/s/..?key=ff", Link{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLinkOrID(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    Link
+		wantErr bool
+	}{
+		{"01HABCDEF", Link{ID: "01HABCDEF"}, false},
+		{"  01HABCDEF\n", Link{ID: "01HABCDEF"}, false},
+		{"https://ots.example.com/s/01ABC?key=ff", Link{Server: "https://ots.example.com", ID: "01ABC", Key: "ff"}, false},
+		{"", Link{}, true},
+		{"https://ots.example.com/nope", Link{}, true},
+		{"..", Link{}, true},
+		{"01ABC%2F..", Link{}, true},
+		{"https://ots.example.com/redeem?id=01ABC#key=ff", Link{Server: "https://ots.example.com", ID: "01ABC", Key: "ff", KeyInFragment: true}, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLinkOrID(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseLinkOrID(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+		}
+		if err == nil && *got != tt.want {
+			t.Errorf("ParseLinkOrID(%q) = %+v, want %+v", tt.raw, *got, tt.want)
+		}
+	}
+}
+
+func TestString_RoundTrip(t *testing.T) {
+	tests := []struct {
+		link Link
+		want string
+	}{
+		{Link{Server: "https://ots.example.com", ID: "01ABC", Key: "deadbeef"}, "https://ots.example.com/s/01ABC?key=deadbeef"},
+		{Link{Server: "https://ots.example.com", ID: "01ABC", Key: "deadbeef", KeyInFragment: true}, "https://ots.example.com/s/01ABC#key=deadbeef"},
+		{Link{Server: "https://example.com/ots", ID: "01ABC", Key: "ff"}, "https://example.com/ots/s/01ABC?key=ff"},
+		{Link{Server: "unix:///run/ots.sock", ID: "01ABC", Key: "ff", KeyInFragment: true}, "unix:///run/ots.sock/s/01ABC#key=ff"},
+		{Link{Server: "https://ots.example.com", ID: "01ABC"}, "https://ots.example.com/s/01ABC"},
+	}
+
+	for _, tt := range tests {
+		if s := tt.link.String(); s != tt.want {
+			t.Errorf("String() = %q, want %q", s, tt.want)
+		}
+		got, err := Parse(tt.link.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *got != tt.link {
+			t.Errorf("Parse(String()) = %+v, want %+v", *got, tt.link)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package output
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maskRun replaces the hidden part of a masked secret; it has a fixed length so the secret's length stays hidden.
+const maskRun = "********"
+
+// minHidden is the fewest characters Mask hides; shorter secrets are hidden entirely.
+const minHidden = 4
+
+// Mask shows only the first and last n characters of secret, e.g. "ghp_********f3a9" for n = 4.
+// Control characters such as line breaks in the visible parts are shown as "·".
+func Mask(secret string, n int) string {
+	runes := []rune(secret)
+	if n < 0 || len(runes) < 2*n+minHidden {
+		return maskRun
+	}
+	return visible(runes[:n]) + maskRun + visible(runes[len(runes)-n:])
+}
+
+func visible(runes []rune) string {
+	var b strings.Builder
+	for _, r := range runes {
+		if unicode.IsControl(r) {
+			r = '·'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,25 @@
+package output
+
+import "testing"
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		secret string
+		n      int
+		want   string
+	}{
+		{"ghp_abcdefghijklmnopf3a9", 4, "ghp_********f3a9"},
+		{"ghp_abcdefghijklmnopf3a9", 0, "********"},
+		{"abcdefghijkl", 4, "abcd********ijkl"},
+		{"abcdefghijk", 4, "********"},
+		{"", 4, "********"},
+		{"line1\nsecret\nline3", 6, "line1·********·line3"},
+		{"pässwörd-geheimnis", 2, "pä********is"},
+	}
+
+	for _, tt := range tests {
+		if got := Mask(tt.secret, tt.n); got != tt.want {
+			t.Errorf("Mask(%q, %d) = %q, want %q", tt.secret, tt.n, got, tt.want)
+		}
+	}
+}
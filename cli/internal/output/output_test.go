@@ -0,0 +1,110 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brentdalling/ots-cli/internal/api"
+	"github.com/brentdalling/ots-cli/internal/crypto"
+)
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{"text", "json", "raw"} {
+		f, err := Parse(s)
+		if err != nil || string(f) != s {
+			t.Errorf("Parse(%q) = %q, %v", s, f, err)
+		}
+	}
+	if _, err := Parse("yaml"); err == nil {
+		t.Error("Parse(yaml) should fail")
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code string
+		exit int
+	}{
+		{"generic", errors.New("boom"), "error", ExitError},
+		{"usage", &UsageError{Err: errors.New("bad flag")}, "usage", ExitUsage},
+		{"canceled", fmt.Errorf("create secret: %w", context.Canceled), "canceled", ExitCanceled},
+		{"not found", fmt.Errorf("retrieve secret: %w", &api.APIError{StatusCode: 404, Message: "Secret not found"}), "not_found", ExitNotFound},
+		{"password", fmt.Errorf("decrypt secret: %w", crypto.ErrPasswordRequired), "password_required", ExitPasswordRequired},
+		{"access password", fmt.Errorf("retrieve secret: %w", api.ErrAccessPasswordRequired), "access_password_required", ExitPasswordRequired},
+		{"access denied", api.ErrAccessDenied, "access_denied", ExitPasswordRequired},
+		{"decrypt", fmt.Errorf("decrypt secret: %w", crypto.ErrDecryptionFailed), "decrypt_failed", ExitDecryptFailed},
+		{"network", fmt.Errorf("create secret: %w", api.ErrUnavailable), "network", ExitNetwork},
+		{"gateway", &api.APIError{StatusCode: 503, Message: "Service Unavailable"}, "network", ExitNetwork},
+		{"too large", fmt.Errorf("create secret: %w", api.ErrPayloadTooLarge), "payload_too_large", ExitError},
+		{"insecure http", fmt.Errorf("create secret: %w to ots.example.com", api.ErrInsecureHTTP), "insecure_http", ExitError},
+		{"rate limited", &api.APIError{StatusCode: 429, Message: "Too Many Requests"}, "rate_limited", ExitServer},
+		{"server", &api.APIError{StatusCode: 500, Message: "oops"}, "server", ExitServer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := Classify(tt.err)
+			if e.Code != tt.code || e.ExitCode != tt.exit {
+				t.Errorf("Classify() = %s/%d, want %s/%d", e.Code, e.ExitCode, tt.code, tt.exit)
+			}
+			if e.Message != tt.err.Error() {
+				t.Errorf("Message = %q, want %q", e.Message, tt.err.Error())
+			}
+		})
+	}
+}
+
+func TestClassify_APIDetails(t *testing.T) {
+	e := Classify(fmt.Errorf("create secret: %w", &api.APIError{StatusCode: 400, Message: "Invalid body"}))
+	if e.StatusCode != 400 || e.APIMessage != "Invalid body" {
+		t.Errorf("StatusCode/APIMessage = %d/%q", e.StatusCode, e.APIMessage)
+	}
+}
+
+func TestPrintError_ExitStatus(t *testing.T) {
+	if got := PrintError(fmt.Errorf("run deploy: %w", ExitStatus(42))); got != 42 {
+		t.Errorf("PrintError(ExitStatus(42)) = %d, want 42", got)
+	}
+}
+
+func TestHint(t *testing.T) {
+	refused := &api.ConnectionError{URL: "http://localhost:1", Kind: api.ConnRefused, Err: errors.New("refused")}
+	if h := Hint(fmt.Errorf("create secret: %w", refused)); !strings.Contains(h, "--server") {
+		t.Errorf("connection refused hint = %q", h)
+	}
+	for _, kind := range []api.ConnectionKind{api.ConnTLS, api.ConnClosed} {
+		h := Hint(&api.ConnectionError{URL: "https://ots.example.com", Kind: kind, Err: errors.New("tls")})
+		if strings.Contains(h, "http://") {
+			t.Errorf("kind %d hint = %q, must not suggest downgrading to plain HTTP", kind, h)
+		}
+	}
+	proxyErr := &api.ConnectionError{URL: "https://ots.example.com", Kind: api.ConnProxy, Err: errors.New("refused")}
+	if h := Hint(proxyErr); !strings.Contains(h, "--proxy") {
+		t.Errorf("proxy hint = %q", h)
+	}
+	if h := Hint(&api.APIError{StatusCode: 407}); !strings.Contains(h, "--proxy") {
+		t.Errorf("proxy authentication hint = %q", h)
+	}
+	pinErr := &api.ConnectionError{URL: "https://ots.example.com", Kind: api.ConnTLS, Err: api.ErrPinMismatch}
+	if h := Hint(pinErr); !strings.Contains(h, "--pin") {
+		t.Errorf("pin mismatch hint = %q", h)
+	}
+	if h := Hint(fmt.Errorf("create secret: %w", api.ErrInsecureHTTP)); !strings.Contains(h, "--insecure-http") {
+		t.Errorf("insecure HTTP hint = %q", h)
+	}
+	if h := Hint(&api.APIError{StatusCode: 429}); h == "" {
+		t.Error("rate limit should have a hint")
+	}
+	if h := Hint(&api.APIError{StatusCode: 429, RetryAfter: 90 * time.Second}); !strings.Contains(h, "1m30s") {
+		t.Errorf("rate limit hint = %q, should say how long to wait", h)
+	}
+	if h := Hint(errors.New("boom")); h != "" {
+		t.Errorf("generic error hint = %q, want none", h)
+	}
+}
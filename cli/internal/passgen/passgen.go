@@ -0,0 +1,251 @@
+// Package passgen generates random passwords and diceware-style passphrases
+// and estimates the strength of passwords people choose themselves.
+package passgen
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"unicode"
+)
+
+const (
+	// DefaultLength is the length of a generated password
+	DefaultLength = 20
+	// MinLength and MaxLength bound the length of a generated password
+	MinLength = 8
+	MaxLength = 256
+	// DefaultWords is the number of words in a generated passphrase
+	DefaultWords = 6
+	// MinWords and MaxWords bound the number of words in a generated passphrase
+	MinWords = 4
+	MaxWords = 32
+	// DefaultSeparator joins the words of a passphrase
+	DefaultSeparator = "-"
+	// MinEntropy is the estimated entropy in bits below which a password is considered weak
+	MinEntropy = 50
+)
+
+// Character sets for generated passwords. Symbols leave out quotes, backslashes and spaces,
+// which are awkward to type in a shell.
+const (
+	lowerChars  = "abcdefghijklmnopqrstuvwxyz"
+	upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars  = "0123456789"
+	symbolChars = "!#%&*+-=?@^_~"
+)
+
+// Classes selects the character classes of a generated password.
+type Classes struct {
+	Lower, Upper, Digits, Symbols bool
+}
+
+// AllClasses uses every character class.
+var AllClasses = Classes{Lower: true, Upper: true, Digits: true, Symbols: true}
+
+// ParseClasses parses a comma-separated list of lower, upper, digits and symbols.
+func ParseClasses(s string) (Classes, error) {
+	var c Classes
+	for _, name := range strings.Split(s, ",") {
+		switch strings.TrimSpace(strings.ToLower(name)) {
+		case "lower":
+			c.Lower = true
+		case "upper":
+			c.Upper = true
+		case "digits":
+			c.Digits = true
+		case "symbols":
+			c.Symbols = true
+		default:
+			return c, fmt.Errorf("unknown character class %q (expected lower, upper, digits or symbols)", strings.TrimSpace(name))
+		}
+	}
+	return c, nil
+}
+
+// sets returns the character set of each selected class.
+func (c Classes) sets() []string {
+	var sets []string
+	if c.Lower {
+		sets = append(sets, lowerChars)
+	}
+	if c.Upper {
+		sets = append(sets, upperChars)
+	}
+	if c.Digits {
+		sets = append(sets, digitChars)
+	}
+	if c.Symbols {
+		sets = append(sets, symbolChars)
+	}
+	return sets
+}
+
+//go:embed wordlist.txt
+var wordlistData string
+
+// Wordlist holds 7776 common English words of 4 to 8 letters, as many as five dice can pick from.
+// Key word codes (see link.KeyWords) are positions in it, so it must never be reordered or changed.
+var Wordlist = strings.Fields(wordlistData)
+
+// Password returns a random password of length characters containing at least one character of each class.
+func Password(length int, classes Classes) (string, error) {
+	sets := classes.sets()
+	if len(sets) == 0 {
+		return "", fmt.Errorf("at least one character class is required")
+	}
+	if length < MinLength || length > MaxLength {
+		return "", fmt.Errorf("password length must be between %d and %d", MinLength, MaxLength)
+	}
+	alphabet := strings.Join(sets, "")
+
+	// Draw whole passwords until one contains every class, so each acceptable password is equally likely
+	for {
+		b := make([]byte, length)
+		for i := range b {
+			n, err := randInt(len(alphabet))
+			if err != nil {
+				return "", err
+			}
+			b[i] = alphabet[n]
+		}
+		if containsAll(string(b), sets) {
+			return string(b), nil
+		}
+	}
+}
+
+func containsAll(s string, sets []string) bool {
+	for _, set := range sets {
+		if !strings.ContainsAny(s, set) {
+			return false
+		}
+	}
+	return true
+}
+
+// Passphrase returns words random words from Wordlist joined by sep.
+func Passphrase(words int, sep string) (string, error) {
+	if words < MinWords || words > MaxWords {
+		return "", fmt.Errorf("passphrase must have between %d and %d words", MinWords, MaxWords)
+	}
+	picked := make([]string, words)
+	for i := range picked {
+		n, err := randInt(len(Wordlist))
+		if err != nil {
+			return "", err
+		}
+		picked[i] = Wordlist[n]
+	}
+	return strings.Join(picked, sep), nil
+}
+
+func randInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("generate password: %w", err)
+	}
+	return int(v.Int64()), nil
+}
+
+// PasswordEntropy returns the entropy in bits of a password generated by Password.
+func PasswordEntropy(length int, classes Classes) float64 {
+	return float64(length) * math.Log2(float64(len(strings.Join(classes.sets(), ""))))
+}
+
+// PassphraseEntropy returns the entropy in bits of a passphrase generated by Passphrase.
+func PassphraseEntropy(words int) float64 {
+	return float64(words) * math.Log2(float64(len(Wordlist)))
+}
+
+// commonPasswords are among the most used passwords; a password built around one is weak whatever its length.
+var commonPasswords = []string{
+	"password", "passwort", "123456", "qwerty", "azerty", "letmein", "welcome", "admin", "iloveyou",
+	"monkey", "dragon", "football", "baseball", "sunshine", "princess", "master", "shadow", "secret",
+	"abc123", "trustno1", "changeme", "login",
+}
+
+// Estimate returns a rough entropy estimate in bits for a password someone chose.
+// Each character counts for the size of the character classes used, except that repeated
+// characters, runs such as abc or 321, and common passwords count for little.
+func Estimate(password string) float64 {
+	if password == "" {
+		return 0
+	}
+	perChar := math.Log2(float64(poolSize(password)))
+
+	folded := strings.Map(asciiLower, password)
+	for _, common := range commonPasswords {
+		if i := strings.Index(folded, common); i >= 0 {
+			// The common part counts as one guess from a short list instead of its characters
+			rest := password[:i] + password[i+len(common):]
+			return 10 + sequenceBits([]rune(rest), perChar)
+		}
+	}
+	return sequenceBits([]rune(password), perChar)
+}
+
+// poolSize returns the number of characters in the classes password draws from.
+func poolSize(password string) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r <= unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+	size := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			size += class.size
+		}
+	}
+	return size
+}
+
+// sequenceBits adds perChar bits per character, but only one bit for a character
+// that repeats the previous one or continues a run of the previous two.
+func sequenceBits(runes []rune, perChar float64) float64 {
+	bits := 0.0
+	for i := range runes {
+		switch {
+		case i > 0 && runes[i] == runes[i-1]:
+			bits++
+		case i > 1 && isStep(runes[i]-runes[i-1]) && runes[i]-runes[i-1] == runes[i-1]-runes[i-2]:
+			bits++
+		default:
+			bits += perChar
+		}
+	}
+	return bits
+}
+
+func isStep(d rune) bool {
+	return d == 1 || d == -1
+}
+
+func asciiLower(r rune) rune {
+	if r >= 'A' && r <= 'Z' {
+		return r + 'a' - 'A'
+	}
+	return r
+}
+
+// Weak reports whether a password's estimated entropy is below MinEntropy.
+func Weak(password string) bool {
+	return Estimate(password) < MinEntropy
+}
@@ -0,0 +1,163 @@
+package passgen
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		length  int
+		classes Classes
+		wantErr bool
+	}{
+		{"all classes", DefaultLength, AllClasses, false},
+		{"digits only", MinLength, Classes{Digits: true}, false},
+		{"letters", 32, Classes{Lower: true, Upper: true}, false},
+		{"no classes", DefaultLength, Classes{}, true},
+		{"too short", MinLength - 1, AllClasses, true},
+		{"too long", MaxLength + 1, AllClasses, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			password, err := Password(tt.length, tt.classes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Password() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(password) != tt.length {
+				t.Errorf("len(%q) = %d, want %d", password, len(password), tt.length)
+			}
+			sets := tt.classes.sets()
+			if !containsAll(password, sets) {
+				t.Errorf("%q is missing a character class", password)
+			}
+			for _, r := range password {
+				if !strings.ContainsRune(strings.Join(sets, ""), r) {
+					t.Errorf("%q contains %q from an unselected class", password, r)
+				}
+			}
+		})
+	}
+}
+
+func TestPassword_Random(t *testing.T) {
+	seen := make(map[string]bool)
+	for range 100 {
+		password, err := Password(MinLength, AllClasses)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[password] {
+			t.Fatalf("Password() repeated %q", password)
+		}
+		seen[password] = true
+	}
+}
+
+func TestParseClasses(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Classes
+		wantErr bool
+	}{
+		{"lower,upper,digits,symbols", AllClasses, false},
+		{"digits", Classes{Digits: true}, false},
+		{" Lower , UPPER ", Classes{Lower: true, Upper: true}, false},
+		{"lower,emoji", Classes{}, true},
+		{"", Classes{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseClasses(tt.in)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("ParseClasses(%q) = %+v, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	if len(Wordlist) != 7776 {
+		t.Fatalf("len(Wordlist) = %d, want 7776", len(Wordlist))
+	}
+	words := make(map[string]bool, len(Wordlist))
+	for _, w := range Wordlist {
+		if words[w] {
+			t.Errorf("Wordlist repeats %q", w)
+		}
+		words[w] = true
+	}
+
+	phrase, err := Passphrase(DefaultWords, " ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	picked := strings.Split(phrase, " ")
+	if len(picked) != DefaultWords {
+		t.Fatalf("Passphrase() = %q, want %d words", phrase, DefaultWords)
+	}
+	for _, w := range picked {
+		if !words[w] {
+			t.Errorf("%q is not in the wordlist", w)
+		}
+	}
+
+	for _, n := range []int{MinWords - 1, MaxWords + 1} {
+		if _, err := Passphrase(n, DefaultSeparator); err == nil {
+			t.Errorf("Passphrase(%d) should fail", n)
+		}
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	if got := PasswordEntropy(10, Classes{Digits: true}); math.Abs(got-10*math.Log2(10)) > 1e-9 {
+		t.Errorf("PasswordEntropy(10, digits) = %f", got)
+	}
+	if got := PassphraseEntropy(6); math.Abs(got-6*math.Log2(7776)) > 1e-9 {
+		t.Errorf("PassphraseEntropy(6) = %f", got)
+	}
+}
+
+func TestWeak(t *testing.T) {
+	tests := []struct {
+		password string
+		weak     bool
+	}{
+		{"", true},
+		{"mypass123", true},
+		{"Password1!", true},
+		{"aaaaaaaaaaaaaaaaaaaa", true},
+		{"abcdefghijklmnop", true},
+		{"1234567890123", true},
+		{"xK9#mQ2$vL7pW4", false},
+		{"correct-horse-battery-staple", false},
+		{"Tr0ub4dor&3x", false},
+	}
+
+	for _, tt := range tests {
+		if got := Weak(tt.password); got != tt.weak {
+			t.Errorf("Weak(%q) = %v (estimate %.0f bits), want %v", tt.password, got, Estimate(tt.password), tt.weak)
+		}
+	}
+}
+
+func TestWeak_Generated(t *testing.T) {
+	password, err := Password(DefaultLength, AllClasses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phrase, err := Passphrase(DefaultWords, DefaultSeparator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{password, phrase} {
+		if Weak(p) {
+			t.Errorf("generated %q is considered weak", p)
+		}
+	}
+}
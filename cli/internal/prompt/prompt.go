@@ -0,0 +1,302 @@
+// Package prompt asks the user for input on the terminal. Prompts go to stderr
+// so they never mix with a command's output, and secrets are read without echo.
+//
+// Every prompt takes a context: canceling it, e.g. with Ctrl-C, abandons the prompt,
+// restores the terminal and returns the context's error.
+package prompt
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// stdin buffers standard input for the line prompts and Secret.
+var stdin = bufio.NewReader(os.Stdin)
+
+// Control characters handled by Secret while the terminal is in raw mode.
+const (
+	keyInterrupt = 0x03 // Ctrl-C
+	keyEOF       = 0x04 // Ctrl-D
+	keyCtrlH     = 0x08 // Backspace on some terminals
+	keyClearLine = 0x15 // Ctrl-U
+	keyEscape    = 0x1b
+	keyBackspace = 0x7f
+)
+
+// IsTerminal reports whether standard input is a terminal.
+func IsTerminal() bool {
+	return term.IsTerminal(stdinFd())
+}
+
+func stdinFd() int {
+	return int(os.Stdin.Fd())
+}
+
+// Password reads a password from the terminal without echoing it.
+func Password(ctx context.Context, prompt string) (string, error) {
+	fd := stdinFd()
+	state, err := term.GetState(fd)
+	if err != nil {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	defer fmt.Fprintln(os.Stderr)
+
+	type result struct {
+		password []byte
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		password, err := term.ReadPassword(fd)
+		done <- result{password, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return "", fmt.Errorf("read password: %w", r.err)
+		}
+		return string(r.password), nil
+	case <-ctx.Done():
+		term.Restore(fd, state)
+		return "", ctx.Err()
+	}
+}
+
+// NewPassword reads a new password twice, asking again until it is not empty and both entries match.
+func NewPassword(ctx context.Context, prompt string) (string, error) {
+	for {
+		first, err := Password(ctx, prompt)
+		if err != nil {
+			return "", err
+		}
+		if first == "" {
+			fmt.Fprintln(os.Stderr, "The password cannot be empty.")
+			continue
+		}
+		second, err := Password(ctx, "Confirm password: ")
+		if err != nil {
+			return "", err
+		}
+		if first == second {
+			return first, nil
+		}
+		fmt.Fprintln(os.Stderr, "The passwords do not match, try again.")
+	}
+}
+
+// Line reads a visible line of input. An empty answer returns def, which the prompt shows in brackets.
+func Line(ctx context.Context, prompt, def string) (string, error) {
+	if def != "" {
+		prompt = fmt.Sprintf("%s [%s]", prompt, def)
+	}
+	fmt.Fprintf(os.Stderr, "%s: ", prompt)
+
+	type result struct {
+		line string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		line, err := stdin.ReadString('\n')
+		done <- result{line, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil && (r.err != io.EOF || r.line == "") {
+			fmt.Fprintln(os.Stderr)
+			return "", fmt.Errorf("read answer: %w", r.err)
+		}
+		if line := strings.TrimSpace(r.line); line != "" {
+			return line, nil
+		}
+		return def, nil
+	case <-ctx.Done():
+		fmt.Fprintln(os.Stderr)
+		return "", ctx.Err()
+	}
+}
+
+// Confirm asks a yes or no question; an empty answer returns def.
+func Confirm(ctx context.Context, question string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		answer, err := Line(ctx, fmt.Sprintf("%s (%s)", question, hint), "")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(os.Stderr, "Please answer y or n.")
+	}
+}
+
+// Secret reads hidden, possibly multi-line input until Ctrl-D.
+// Enter starts a new line, Backspace and Ctrl-U edit the current line, and Ctrl-C cancels
+// with context.Canceled. A single trailing newline is dropped.
+func Secret(ctx context.Context, prompt string) ([]byte, error) {
+	fmt.Fprintln(os.Stderr, prompt)
+
+	fd := stdinFd()
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("read secret: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	type result struct {
+		secret []byte
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		secret, err := readHidden(stdin)
+		done <- result{secret, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.secret, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// WaitKey shows message and waits for a single key press. Ctrl-C returns context.Canceled.
+func WaitKey(ctx context.Context, message string) error {
+	fmt.Fprint(os.Stderr, message)
+	defer fmt.Fprintln(os.Stderr)
+
+	fd := stdinFd()
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("read key: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	done := make(chan error, 1)
+	go func() {
+		b, err := stdin.ReadByte()
+		// A key such as an arrow sends several bytes; drop the rest so they do not answer the next prompt
+		stdin.Discard(stdin.Buffered())
+		if err == nil && b == keyInterrupt {
+			err = context.Canceled
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return fmt.Errorf("read key: %w", err)
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// readHidden applies the editing keys of Secret to raw terminal input from r.
+func readHidden(r io.ByteReader) ([]byte, error) {
+	var buf []byte
+	var prev byte
+	for {
+		b, err := r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read secret: %w", err)
+		}
+
+		switch b {
+		case keyInterrupt:
+			clear(buf)
+			return nil, context.Canceled
+		case keyEOF:
+			return trimNewline(buf), nil
+		case '\r':
+			buf = append(buf, '\n')
+		case '\n':
+			// Raw input sends Enter as \r; a pasted \r\n is one line break
+			if prev != '\r' {
+				buf = append(buf, '\n')
+			}
+		case keyBackspace, keyCtrlH:
+			if len(buf) > 0 && buf[len(buf)-1] != '\n' {
+				_, size := utf8.DecodeLastRune(buf)
+				clear(buf[len(buf)-size:])
+				buf = buf[:len(buf)-size]
+			}
+		case keyClearLine:
+			start := len(buf)
+			for start > 0 && buf[start-1] != '\n' {
+				start--
+			}
+			clear(buf[start:])
+			buf = buf[:start]
+		case keyEscape:
+			// Arrow and function keys send escape sequences, which are not part of the secret
+			if err := skipEscape(r); err != nil {
+				return nil, fmt.Errorf("read secret: %w", err)
+			}
+		default:
+			buf = append(buf, b)
+		}
+		prev = b
+	}
+	return trimNewline(buf), nil
+}
+
+// skipEscape consumes the rest of an escape sequence such as ESC [ A.
+func skipEscape(r io.ByteReader) error {
+	b, err := r.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return ignoreEOF(err)
+	}
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return ignoreEOF(err)
+		}
+		// A CSI sequence ends with a byte in @ to ~; SS3 sequences are a single byte
+		if b >= 0x40 && b <= 0x7e {
+			return nil
+		}
+	}
+}
+
+func ignoreEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// trimNewline drops one trailing newline, which typing Enter before Ctrl-D leaves.
+func trimNewline(buf []byte) []byte {
+	if n := len(buf); n > 0 && buf[n-1] == '\n' {
+		return buf[:n-1]
+	}
+	return buf
+}
@@ -0,0 +1,46 @@
+package prompt
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadHidden(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		err   error
+	}{
+		{"single line", "hunter2\x04", "hunter2", nil},
+		{"enter before ctrl-d", "hunter2\r\x04", "hunter2", nil},
+		{"multi-line", "line one\rline two\r\x04", "line one\nline two", nil},
+		{"pasted crlf", "a\r\nb\r\n\x04", "a\nb", nil},
+		{"pasted lf", "a\nb\x04", "a\nb", nil},
+		{"blank lines kept", "a\r\rb\x04", "a\n\nb", nil},
+		{"backspace", "hunx\x7fter2\x04", "hunter2", nil},
+		{"ctrl-h", "ab\x08c\x04", "ac", nil},
+		{"backspace multibyte", "pässx\x7f\x7f\x04", "päs", nil},
+		{"backspace stops at line start", "a\r\x7f\x7fb\x04", "a\nb", nil},
+		{"ctrl-u", "first\rwrong\x15second\x04", "first\nsecond", nil},
+		{"arrow keys ignored", "ab\x1b[Dc\x1bOA\x04", "abc", nil},
+		{"end of input", "abc\r", "abc", nil},
+		{"empty", "\x04", "", nil},
+		{"ctrl-c", "secret\x03", "", context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readHidden(bufio.NewReader(strings.NewReader(tt.input)))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("readHidden() error = %v, want %v", err, tt.err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("readHidden() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+// Package ots shares and redeems one-time secrets from Go programs.
+//
+// Secrets are encrypted on the client with a random key that only ever appears in the
+// link, so the server stores ciphertext it cannot read. Share returns that link; Redeem
+// takes it and returns the plaintext. Secrets too large for a single server-side secret
+// are split into parts transparently.
+//
+//	shared, err := ots.Share(ctx, []byte("db password"),
+//		ots.WithServer("https://ots.example.com"),
+//		ots.WithExpiresIn("24h"))
+//	...
+//	plaintext, err := ots.Redeem(ctx, shared.Link, "")
+//
+// Programs making several calls should create a Client once with NewClient.
+package ots
+
+import (
+	"context"
+	"errors"
+
+	"github.com/brentdalling/ots-cli/internal/api"
+	"github.com/brentdalling/ots-cli/internal/chunk"
+	"github.com/brentdalling/ots-cli/internal/crypto"
+	"github.com/brentdalling/ots-cli/internal/link"
+)
+
+// MaxReads is the largest read limit the server accepts.
+const MaxReads = api.MaxReadsLimit
+
+// Errors returned by this package can be matched with errors.Is.
+var (
+	// ErrNoServer is returned when neither WithServer nor the link names a server
+	ErrNoServer = errors.New("no server given")
+	// ErrMissingKey is returned for links without the decryption key
+	ErrMissingKey = errors.New("missing key parameter in URL")
+
+	// ErrNotFound means the secret does not exist, has expired or was already consumed
+	ErrNotFound = api.ErrNotFound
+	// ErrAccessPasswordRequired means the secret needs WithAccessPassword; no read was consumed
+	ErrAccessPasswordRequired = api.ErrAccessPasswordRequired
+	// ErrAccessDenied means the access password was wrong; no read was consumed
+	ErrAccessDenied = api.ErrAccessDenied
+	// ErrPasswordRequired means the secret has a password layer and none was given
+	ErrPasswordRequired = crypto.ErrPasswordRequired
+	// ErrDecryptionFailed means the key or password is wrong, or the data was tampered with
+	ErrDecryptionFailed = crypto.ErrDecryptionFailed
+	// ErrPayloadTooLarge means the secret exceeds the server's size limits
+	ErrPayloadTooLarge = api.ErrPayloadTooLarge
+	// ErrRateLimited means the server is limiting requests
+	ErrRateLimited = api.ErrRateLimited
+	// ErrUnavailable means the server could not be reached or a gateway in front of it failed
+	ErrUnavailable = api.ErrUnavailable
+	// ErrDigestMismatch means a part of a large secret was corrupted or substituted
+	ErrDigestMismatch = chunk.ErrDigestMismatch
+	// ErrInsecureHTTP means the server uses plain HTTP and is not on this machine; see WithInsecureHTTP
+	ErrInsecureHTTP = api.ErrInsecureHTTP
+	// ErrPinMismatch means the server's public key does not match WithPin
+	ErrPinMismatch = api.ErrPinMismatch
+)
+
+type (
+	// APIError is an error response from the server; see its StatusCode and Message.
+	APIError = api.APIError
+	// ConnectionError says why the server could not be reached.
+	ConnectionError = api.ConnectionError
+	// PartialError reports a large secret that failed part-way, with the parts already stored or consumed.
+	PartialError = chunk.PartialError
+	// KDF describes the password key derivation function and its cost; see DefaultKDF.
+	KDF = crypto.KDFParams
+)
+
+// Key derivation function names for DefaultKDF.
+const (
+	KDFArgon2id = crypto.KDFArgon2id
+	KDFScrypt   = crypto.KDFScrypt
+	KDFPBKDF2   = crypto.KDFPBKDF2
+)
+
+// DefaultKDF returns the named KDF with recommended costs.
+func DefaultKDF(name string) (KDF, error) {
+	return crypto.DefaultKDFParams(name)
+}
+
+// KeyWords renders a shared secret's key as a word code of KeyWordCount words, which is easier
+// to read out or type than hex. The last word is a checksum; WithKey accepts either form.
+func KeyWords(key string) (string, error) {
+	return link.KeyWords(key)
+}
+
+// KeyWordCount is the number of words in a key's word code.
+const KeyWordCount = link.KeyWordCount
+
+// Client talks to one server. It is safe for concurrent use.
+type Client struct {
+	api      *api.Client
+	defaults settings
+}
+
+// NewClient creates a client for the server given with WithServer.
+// The other options become the defaults for every call.
+func NewClient(opts ...Option) (*Client, error) {
+	s := settings{}.apply(opts)
+	if s.server == "" {
+		return nil, ErrNoServer
+	}
+
+	c, err := api.NewClientWithOptions(s.server, s.api)
+	if err != nil {
+		return nil, err
+	}
+	if s.httpClient != nil {
+		c.HTTPClient = s.httpClient
+	}
+	return &Client{api: c, defaults: s}, nil
+}
+
+// Server returns the server the client talks to.
+func (c *Client) Server() string {
+	return c.api.BaseURL
+}
+
+// Delete destroys a secret, given its link or ID, before it is read or expires.
+// Returns ErrNotFound if it was already consumed, expired or deleted.
+// The parts of a large secret are not deleted; pass each ID in Shared.Parts.
+func (c *Client) Delete(ctx context.Context, linkOrID string) error {
+	l, err := link.ParseLinkOrID(linkOrID)
+	if err != nil {
+		return err
+	}
+	return c.api.DeleteSecretContext(ctx, l.ID)
+}
+
+// Share encrypts secret and stores it on the server, returning the link to send.
+// It is the same as NewClient followed by Client.Share.
+func Share(ctx context.Context, secret []byte, opts ...Option) (*Shared, error) {
+	c, err := NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return c.Share(ctx, secret)
+}
+
+// Redeem retrieves and decrypts the secret behind link, consuming a read.
+// password is the secret's password, or "" if it has none.
+// The server in the link is used unless WithServer overrides it.
+func Redeem(ctx context.Context, rawLink, password string, opts ...Option) ([]byte, error) {
+	env, err := Fetch(ctx, rawLink, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return env.Open(ctx, password)
+}
+
+// Fetch retrieves the secret behind link without decrypting it, consuming a read.
+// The server in the link is used unless WithServer overrides it.
+func Fetch(ctx context.Context, rawLink string, opts ...Option) (*Envelope, error) {
+	c, err := clientForLink(rawLink, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.Fetch(ctx, rawLink)
+}
+
+// Delete destroys a secret, given its link or ID, before it is read or expires.
+// The server in the link is used unless WithServer overrides it; a bare ID needs WithServer.
+func Delete(ctx context.Context, linkOrID string, opts ...Option) error {
+	c, err := clientForLink(linkOrID, opts)
+	if err != nil {
+		return err
+	}
+	return c.Delete(ctx, linkOrID)
+}
+
+// clientForLink creates a client for the server in a link or ID, unless opts name one.
+func clientForLink(linkOrID string, opts []Option) (*Client, error) {
+	l, err := link.ParseLinkOrID(linkOrID)
+	if err != nil {
+		return nil, err
+	}
+	if l.Server != "" {
+		opts = append([]Option{WithServer(l.Server)}, opts...)
+	}
+	return NewClient(opts...)
+}
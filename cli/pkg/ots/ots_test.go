@@ -0,0 +1,292 @@
+package ots_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brentdalling/ots-cli/internal/apitest"
+	"github.com/brentdalling/ots-cli/pkg/ots"
+)
+
+func TestShareRedeem(t *testing.T) {
+	f, server := apitest.Start(t)
+	ctx := context.Background()
+
+	shared, err := ots.Share(ctx, []byte("db password"), ots.WithServer(server), ots.WithExpiresIn("24h"), ots.WithMaxReads(3))
+	if err != nil {
+		t.Fatalf("Share() failed: %v", err)
+	}
+	if !strings.HasPrefix(shared.Link, server+"/s/"+shared.ID+"?key=") {
+		t.Errorf("Link = %q", shared.Link)
+	}
+	if shared.ExpiresAt == nil || shared.ExpiresAt.UnixMilli() != apitest.ExpiresAt || shared.RemainingReads != 3 || shared.Parts != nil {
+		t.Errorf("Shared = %+v", shared)
+	}
+
+	req, _ := f.Stored(shared.ID)
+	if req.ExpiresIn != "24h" || req.MaxReads == nil || *req.MaxReads != 3 {
+		t.Errorf("request expiresIn = %v, maxReads = %v", req.ExpiresIn, req.MaxReads)
+	}
+	if sent, _ := json.Marshal(req); strings.Contains(string(sent), strings.SplitAfter(shared.Link, "key=")[1]) {
+		t.Error("the decryption key must never be sent to the server")
+	}
+
+	for read := 1; read <= 3; read++ {
+		got, err := ots.Redeem(ctx, shared.Link, "")
+		if err != nil {
+			t.Fatalf("Redeem() %d failed: %v", read, err)
+		}
+		if string(got) != "db password" {
+			t.Errorf("Redeem() %d = %q", read, got)
+		}
+	}
+
+	if _, err := ots.Redeem(ctx, shared.Link, ""); !errors.Is(err, ots.ErrNotFound) {
+		t.Errorf("fourth Redeem() = %v, want ErrNotFound", err)
+	}
+}
+
+func TestKeyInFragment(t *testing.T) {
+	_, server := apitest.Start(t)
+	ctx := context.Background()
+
+	shared, err := ots.Share(ctx, []byte("fragment"), ots.WithServer(server), ots.WithKeyInFragment())
+	if err != nil {
+		t.Fatalf("Share() failed: %v", err)
+	}
+	if !strings.HasPrefix(shared.Link, server+"/s/"+shared.ID+"#key=") {
+		t.Errorf("Link = %q", shared.Link)
+	}
+	if got, err := ots.Redeem(ctx, shared.Link, ""); err != nil || string(got) != "fragment" {
+		t.Errorf("Redeem() = %q, %v", got, err)
+	}
+}
+
+func TestSplitKey(t *testing.T) {
+	_, server := apitest.Start(t)
+	ctx := context.Background()
+
+	share := func() *ots.Shared {
+		shared, err := ots.Share(ctx, []byte("split"), ots.WithServer(server), ots.WithSplitKey())
+		if err != nil {
+			t.Fatalf("Share() failed: %v", err)
+		}
+		if shared.Link != server+"/s/"+shared.ID || len(shared.Key) != 64 {
+			t.Fatalf("Link = %q, Key = %q", shared.Link, shared.Key)
+		}
+		return shared
+	}
+
+	shared := share()
+	if _, err := ots.Redeem(ctx, shared.Link, ""); !errors.Is(err, ots.ErrMissingKey) {
+		t.Errorf("Redeem() without a key = %v, want ErrMissingKey", err)
+	}
+	// A mistyped key is rejected before the read is consumed
+	words, err := ots.KeyWords(shared.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ots.Redeem(ctx, shared.Link, "", ots.WithKey(words+"-extra")); err == nil {
+		t.Error("Redeem() with a mistyped key should fail")
+	}
+	if got, err := ots.Redeem(ctx, shared.Link, "", ots.WithKey(words)); err != nil || string(got) != "split" {
+		t.Errorf("Redeem(WithKey(words)) = %q, %v", got, err)
+	}
+
+	shared = share()
+	if got, err := ots.Redeem(ctx, shared.Link, "", ots.WithKey(shared.Key)); err != nil || string(got) != "split" {
+		t.Errorf("Redeem(WithKey(hex)) = %q, %v", got, err)
+	}
+}
+
+func TestPassword(t *testing.T) {
+	_, server := apitest.Start(t)
+	ctx := context.Background()
+	kdf, err := ots.DefaultKDF(ots.KDFScrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kdf.N = 1 << 10
+
+	shared, err := ots.Share(ctx, []byte("s3cret"), ots.WithServer(server), ots.WithPassword("hunter2"), ots.WithKDF(kdf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := ots.Fetch(ctx, shared.Link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !env.PasswordProtected || env.KDF != ots.KDFScrypt || env.Version != 2 {
+		t.Errorf("Envelope = %+v", env)
+	}
+
+	// The read is consumed, so a missing or wrong password can be corrected
+	if _, err := env.Open(ctx, ""); !errors.Is(err, ots.ErrPasswordRequired) {
+		t.Errorf("Open() without password = %v, want ErrPasswordRequired", err)
+	}
+	if _, err := env.Open(ctx, "wrong"); !errors.Is(err, ots.ErrDecryptionFailed) {
+		t.Errorf("Open() with wrong password = %v, want ErrDecryptionFailed", err)
+	}
+	if got, err := env.Open(ctx, "hunter2"); err != nil || string(got) != "s3cret" {
+		t.Errorf("Open() = %q, %v", got, err)
+	}
+}
+
+func TestAccessPassword(t *testing.T) {
+	_, server := apitest.Start(t)
+	ctx := context.Background()
+	client, err := ots.NewClient(ots.WithServer(server))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shared, err := client.Share(ctx, []byte("gated"), ots.WithAccessPassword("open sesame"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Redeem(ctx, shared.Link, ""); !errors.Is(err, ots.ErrAccessPasswordRequired) {
+		t.Errorf("without access password: %v, want ErrAccessPasswordRequired", err)
+	}
+	if _, err := client.Redeem(ctx, shared.Link, "", ots.WithAccessPassword("wrong")); !errors.Is(err, ots.ErrAccessDenied) {
+		t.Errorf("wrong access password: %v, want ErrAccessDenied", err)
+	}
+	if got, err := client.Redeem(ctx, shared.Link, "", ots.WithAccessPassword("open sesame")); err != nil || string(got) != "gated" {
+		t.Errorf("right access password: %q, %v", got, err)
+	}
+}
+
+func TestLargeSecret(t *testing.T) {
+	f, server := apitest.Start(t)
+	ctx := context.Background()
+
+	data := make([]byte, 200*1024)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+
+	var uploads int
+	shared, err := ots.Share(ctx, data, ots.WithServer(server), ots.WithProgress(func(index, total int) { uploads = total }))
+	if err != nil {
+		t.Fatalf("Share() failed: %v", err)
+	}
+	if len(shared.Parts) < 2 || uploads != len(shared.Parts) {
+		t.Fatalf("Parts = %d, progress total = %d", len(shared.Parts), uploads)
+	}
+
+	var downloads int
+	got, err := ots.Redeem(ctx, shared.Link, "", ots.WithProgress(func(index, total int) { downloads++ }))
+	if err != nil {
+		t.Fatalf("Redeem() failed: %v", err)
+	}
+	if !bytes.Equal(got, data) || downloads != len(shared.Parts) {
+		t.Errorf("reassembled %d bytes with %d downloads, want %d bytes and %d", len(got), downloads, len(data), len(shared.Parts))
+	}
+	for _, id := range shared.Parts {
+		if _, ok := f.Stored(id); ok {
+			t.Errorf("part %s was not consumed", id)
+		}
+	}
+
+	if _, err := ots.Share(ctx, data, ots.WithServer(server), ots.WithLegacyFormat()); !errors.Is(err, ots.ErrPayloadTooLarge) {
+		t.Errorf("legacy Share() = %v, want ErrPayloadTooLarge", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	_, server := apitest.Start(t)
+	ctx := context.Background()
+
+	shared, err := ots.Share(ctx, []byte("oops"), ots.WithServer(server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ots.Delete(ctx, shared.Link); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := ots.Delete(ctx, shared.ID, ots.WithServer(server)); !errors.Is(err, ots.ErrNotFound) {
+		t.Errorf("second Delete() = %v, want ErrNotFound", err)
+	}
+	if _, err := ots.Redeem(ctx, shared.Link, ""); !errors.Is(err, ots.ErrNotFound) {
+		t.Errorf("Redeem() after Delete() = %v, want ErrNotFound", err)
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	// Socket paths are limited to about 100 bytes, which t.TempDir can exceed
+	dir, err := os.MkdirTemp("", "ots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	l, err := net.Listen("unix", filepath.Join(dir, "ots.sock"))
+	if err != nil {
+		t.Skipf("Unix sockets unavailable: %v", err)
+	}
+	srv := &http.Server{Handler: apitest.New()}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	ctx := context.Background()
+	server := "unix://" + filepath.Join(dir, "ots.sock")
+	shared, err := ots.Share(ctx, []byte("over the socket"), ots.WithServer(server))
+	if err != nil {
+		t.Fatalf("Share() failed: %v", err)
+	}
+	if !strings.HasPrefix(shared.Link, server+"/s/") {
+		t.Errorf("Link = %q", shared.Link)
+	}
+	if got, err := ots.Redeem(ctx, shared.Link, ""); err != nil || string(got) != "over the socket" {
+		t.Errorf("Redeem() = %q, %v", got, err)
+	}
+}
+
+func TestInvalidArguments(t *testing.T) {
+	_, server := apitest.Start(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		err  func() error
+		want error
+	}{
+		{"no server", func() error { _, err := ots.Share(ctx, []byte("x")); return err }, ots.ErrNoServer},
+		{"bare ID", func() error { return ots.Delete(ctx, "01ABC") }, ots.ErrNoServer},
+		{"missing key", func() error { _, err := ots.Redeem(ctx, server+"/s/01ABC", ""); return err }, ots.ErrMissingKey},
+		{"too many reads", func() error {
+			_, err := ots.Share(ctx, []byte("x"), ots.WithServer(server), ots.WithMaxReads(ots.MaxReads+1))
+			return err
+		}, nil},
+		{"burn with reads", func() error {
+			_, err := ots.Share(ctx, []byte("x"), ots.WithServer(server), ots.WithBurnAfterRead(), ots.WithMaxReads(2))
+			return err
+		}, nil},
+		{"legacy with access password", func() error {
+			_, err := ots.Share(ctx, []byte("x"), ots.WithServer(server), ots.WithLegacyFormat(), ots.WithAccessPassword("a"))
+			return err
+		}, nil},
+		{"empty secret", func() error { _, err := ots.Share(ctx, nil, ots.WithServer(server)); return err }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.err()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
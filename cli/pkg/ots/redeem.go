@@ -0,0 +1,123 @@
+package ots
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/brentdalling/ots-cli/internal/chunk"
+	"github.com/brentdalling/ots-cli/internal/crypto"
+	"github.com/brentdalling/ots-cli/internal/link"
+)
+
+// Envelope is a retrieved secret that has not been decrypted yet.
+// Its read is already consumed, so a caller can ask for the password
+// and call Open again if it was missing or wrong.
+type Envelope struct {
+	// ID identifies the secret on the server
+	ID string
+	// PasswordProtected reports whether Open needs a password
+	PasswordProtected bool
+	// Version is the encryption format, 1 for legacy AES-CBC or 2 for AES-GCM
+	Version int
+	// KDF names the password key derivation function
+	KDF string
+
+	enc      *crypto.EncryptedSecret
+	client   *Client
+	progress func(index, total int)
+}
+
+// Redeem retrieves and decrypts the secret behind link from the client's server, consuming a read.
+// password is the secret's password, or "" if it has none.
+func (c *Client) Redeem(ctx context.Context, rawLink, password string, opts ...Option) ([]byte, error) {
+	env, err := c.Fetch(ctx, rawLink, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return env.Open(ctx, password)
+}
+
+// Fetch retrieves the secret behind link from the client's server without decrypting it,
+// consuming a read. If the secret has an access password, pass it with WithAccessPassword;
+// a missing or wrong one returns ErrAccessPasswordRequired or ErrAccessDenied without consuming the read.
+func (c *Client) Fetch(ctx context.Context, rawLink string, opts ...Option) (*Envelope, error) {
+	s := c.defaults.apply(opts)
+
+	l, err := link.Parse(rawLink)
+	if err != nil {
+		return nil, err
+	}
+	// A key given separately is checked before the read is consumed
+	if s.key != "" {
+		if l.Key != "" {
+			return nil, fmt.Errorf("the link already contains a key")
+		}
+		if l.Key, err = link.ParseKey(s.key); err != nil {
+			return nil, err
+		}
+	}
+	if l.Key == "" {
+		return nil, ErrMissingKey
+	}
+
+	var accessHash string
+	if s.accessPassword != "" {
+		accessHash, err = crypto.AccessPasswordHash(s.accessPassword, l.Key)
+		if err != nil {
+			return nil, fmt.Errorf("hash access password: %w", err)
+		}
+	}
+
+	resp, err := c.api.RetrieveSecretWithAccessContext(ctx, l.ID, accessHash)
+	if err != nil {
+		return nil, fmt.Errorf("retrieve secret: %w", err)
+	}
+
+	kdf, err := crypto.ParseKDFParams(resp.KDF, resp.KDFParams)
+	if err != nil {
+		return nil, fmt.Errorf("read KDF parameters: %w", err)
+	}
+
+	version := crypto.VersionFromParams(resp.KDFParams)
+	protected, _ := resp.KDFParams["isPasswordProtected"].(bool)
+	return &Envelope{
+		ID:                l.ID,
+		PasswordProtected: protected,
+		Version:           version,
+		KDF:               kdf.Name,
+		enc: &crypto.EncryptedSecret{
+			Ciphertext: resp.Ciphertext,
+			IV:         resp.IV,
+			Salt:       resp.Salt,
+			Key:        l.Key,
+			Version:    version,
+			KDF:        kdf,
+		},
+		client:   c,
+		progress: s.progress,
+	}, nil
+}
+
+// Open decrypts the secret, downloading and reassembling the parts of a large one.
+// Returns ErrPasswordRequired if the secret has a password and none was given,
+// and ErrDecryptionFailed for a wrong password; Open can then be called again.
+// Downloading the parts consumes their reads, so it cannot be repeated after a failure.
+func (e *Envelope) Open(ctx context.Context, password string) ([]byte, error) {
+	plaintext, err := crypto.DecryptBytes(e.enc, password)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt secret: %w", err)
+	}
+	if !chunk.IsManifest(plaintext) {
+		return plaintext, nil
+	}
+
+	m, err := chunk.Decode(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("read multi-part secret: %w", err)
+	}
+	data, err := chunk.Download(ctx, e.client.api, m, e.progress)
+	if err != nil {
+		return nil, fmt.Errorf("reassemble multi-part secret: %w", err)
+	}
+	return data, nil
+}
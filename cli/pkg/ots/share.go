@@ -0,0 +1,169 @@
+package ots
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brentdalling/ots-cli/internal/api"
+	"github.com/brentdalling/ots-cli/internal/chunk"
+	"github.com/brentdalling/ots-cli/internal/crypto"
+	"github.com/brentdalling/ots-cli/internal/link"
+)
+
+// Shared describes a secret stored by Share.
+type Shared struct {
+	// ID identifies the secret on the server, e.g. for Delete
+	ID string
+	// Link is what the recipient needs: the server, the ID and the decryption key.
+	// With WithSplitKey it leaves the key out, and Key must be sent separately
+	Link string
+	// Key is the hex-encoded decryption key, which is also part of Link unless WithSplitKey was used
+	Key string
+	// ExpiresAt is when the server destroys the secret, if it reported it
+	ExpiresAt *time.Time
+	// RemainingReads is how many times the link can be redeemed
+	RemainingReads int
+	// Parts are the IDs of the parts a large secret was split into, or nil
+	Parts []string
+}
+
+// Share encrypts secret and stores it on the server, returning the link to send.
+// The decryption key is only part of the link; it is never sent to the server.
+// Secrets too large for one server-side secret are uploaded in parts, which
+// Redeem reassembles; a failure part-way is reported as *PartialError.
+func (c *Client) Share(ctx context.Context, secret []byte, opts ...Option) (*Shared, error) {
+	s := c.defaults.apply(opts)
+	if err := s.validateShare(); err != nil {
+		return nil, err
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("secret cannot be empty")
+	}
+
+	// Expiry and read limits apply to the secret and, for large payloads, to every part
+	var meta api.CreateSecretRequest
+	meta.ExpiresIn = s.expiresIn
+	if s.burnAfterRead {
+		burn := true
+		meta.BurnAfterRead = &burn
+	} else if s.maxReads > 0 {
+		maxReads := s.maxReads
+		meta.MaxReads = &maxReads
+	}
+
+	encrypted, err := crypto.EncryptBytes(secret, s.password, s.cryptoOptions())
+	if err != nil {
+		return nil, fmt.Errorf("encrypt secret: %w", err)
+	}
+
+	var parts []string
+	if !chunk.Fits(encrypted.Ciphertext) {
+		if s.legacy {
+			return nil, fmt.Errorf("%w: %d bytes encrypt to %d characters, but the server accepts at most %d (the legacy format cannot be split into parts)",
+				ErrPayloadTooLarge, len(secret), len(encrypted.Ciphertext), api.MaxCiphertextLength)
+		}
+
+		var manifest *chunk.Manifest
+		encrypted, manifest, err = c.uploadParts(ctx, secret, meta, s)
+		if err != nil {
+			return nil, err
+		}
+		parts = manifest.IDs()
+	}
+
+	kdfParams := encrypted.KDF.Map()
+	kdfParams["isPasswordProtected"] = s.password != ""
+	kdfParams["version"] = encrypted.Version
+
+	req := meta
+	req.Ciphertext = encrypted.Ciphertext
+	req.IV = encrypted.IV
+	req.Salt = encrypted.Salt
+	req.KDF = encrypted.KDF.Name
+	req.KDFParams = kdfParams
+
+	// Only the shared secret is gated: part IDs are known solely from its encrypted manifest
+	if s.accessPassword != "" {
+		req.AccessPasswordHash, err = crypto.AccessPasswordHash(s.accessPassword, encrypted.Key)
+		if err != nil {
+			return nil, fmt.Errorf("hash access password: %w", err)
+		}
+	}
+
+	resp, err := c.api.CreateSecretContext(ctx, &req)
+	if err != nil {
+		return nil, c.discardParts(ctx, parts, fmt.Errorf("create secret: %w", err))
+	}
+
+	shared := &Shared{
+		ID:             resp.ID,
+		Link:           linkFor(c.api.BaseURL, resp.ID, encrypted.Key, s),
+		Key:            encrypted.Key,
+		RemainingReads: resp.RemainingReads,
+		Parts:          parts,
+	}
+	if resp.ExpiresAt != nil {
+		expiresAt := time.UnixMilli(*resp.ExpiresAt).UTC()
+		shared.ExpiresAt = &expiresAt
+	}
+	return shared, nil
+}
+
+// linkFor builds the link to a shared secret, without its key for WithSplitKey.
+func linkFor(server, id, key string, s settings) string {
+	l := &link.Link{Server: server, ID: id, Key: key, KeyInFragment: s.keyInFragment}
+	if s.splitKey {
+		l.Key = ""
+	}
+	return l.String()
+}
+
+// validateShare rejects option combinations the server or the format cannot honor.
+func (s settings) validateShare() error {
+	if s.maxReads < 0 || s.maxReads > MaxReads {
+		return fmt.Errorf("max reads must be between 1 and %d", MaxReads)
+	}
+	if s.burnAfterRead && s.maxReads > 1 {
+		return fmt.Errorf("burn after read allows a single read and cannot be combined with %d max reads", s.maxReads)
+	}
+	if s.legacy && s.accessPassword != "" {
+		return fmt.Errorf("the legacy format cannot be combined with an access password (the web interface cannot send one)")
+	}
+	return nil
+}
+
+// uploadParts stores an oversized secret as several part secrets and returns the manifest
+// along with its encrypted form, which takes the place of the secret itself.
+func (c *Client) uploadParts(ctx context.Context, secret []byte, meta api.CreateSecretRequest, s settings) (*crypto.EncryptedSecret, *chunk.Manifest, error) {
+	opts := s.cryptoOptions()
+	manifest, err := chunk.Upload(ctx, c.api, secret, meta, opts, s.progress)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create multi-part secret: %w", err)
+	}
+
+	encoded, err := manifest.Encode()
+	if err != nil {
+		return nil, nil, c.discardParts(ctx, manifest.IDs(), fmt.Errorf("encode manifest: %w", err))
+	}
+
+	encrypted, err := crypto.EncryptBytes(encoded, s.password, opts)
+	if err != nil {
+		return nil, nil, c.discardParts(ctx, manifest.IDs(), fmt.Errorf("encrypt manifest: %w", err))
+	}
+	if !chunk.Fits(encrypted.Ciphertext) {
+		return nil, nil, c.discardParts(ctx, manifest.IDs(), fmt.Errorf("%w: the manifest for %d parts does not fit in one secret", ErrPayloadTooLarge, len(manifest.Chunks)))
+	}
+	return encrypted, manifest, nil
+}
+
+// discardParts deletes the stored parts of a secret that could not be shared, since nothing
+// links to them, and adds any that could not be deleted to err.
+func (c *Client) discardParts(ctx context.Context, parts []string, err error) error {
+	if left := chunk.Delete(ctx, c.api, parts); len(left) > 0 {
+		return fmt.Errorf("%w\n  parts stored and could not be deleted: %s", err, strings.Join(left, ", "))
+	}
+	return err
+}
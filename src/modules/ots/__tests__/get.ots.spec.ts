@@ -0,0 +1,199 @@
+import { describe, it, expect, beforeAll, afterAll } from 'bun:test';
+
+/**
+ * Test suite for GET /api/v1/ots/:id endpoint.
+ * Tests access password enforcement, format negotiation and read accounting.
+ */
+describe('GET /api/v1/ots/:id', () => {
+    const accessPasswordHash = 'YWNjZXNzLXBhc3N3b3JkLWhhc2gtMDEyMzQ1Njc4OQ';
+
+    beforeAll(() => {
+        process.env.DB_ENCRYPTION_KEY = 'test-key-12345678901234567890123456789012';
+        process.env.DB_PATH = ':memory:';
+        process.env.PORT = '3001';
+    });
+
+    afterAll(() => {
+        delete process.env.DB_ENCRYPTION_KEY;
+        delete process.env.DB_PATH;
+        delete process.env.PORT;
+    });
+
+    /**
+     * Creates a secret and returns its ID.
+     */
+    async function createSecret(app: any, extra: Record<string, unknown>): Promise<string> {
+        const response = await app.inject({
+            method: 'POST',
+            url: '/api/v1/ots/',
+            payload: {
+                ciphertext: 'c2VjcmV0',
+                iv: 'iv01234567890',
+                salt: 'salt01234567890',
+                kdf: 'pbkdf2',
+                kdfParams: { iterations: 10000 },
+                expiresIn: '1h',
+                ...extra,
+            },
+        });
+        expect(response.statusCode).toBe(201);
+        return JSON.parse(response.body).id;
+    }
+
+    /**
+     * Returns the reads left on a secret, or undefined once it is gone.
+     */
+    async function remainingReads(id: string): Promise<number | undefined> {
+        const { createDb } = await import('../../../db');
+        const { findSecretById } = await import('../repo');
+        return findSecretById(createDb(), id)?.remainingReads;
+    }
+
+    it('refuses a missing access password with 401 without consuming a read', async () => {
+        const { buildServer } = await import('../../../server');
+        const app = await buildServer();
+        app.log.level = 'silent';
+
+        try {
+            const id = await createSecret(app, { maxReads: 2, accessPasswordHash });
+
+            const response = await app.inject({ method: 'GET', url: `/api/v1/ots/${id}` });
+
+            expect(response.statusCode).toBe(401);
+            expect(JSON.parse(response.body).ciphertext).toBeUndefined();
+            expect(await remainingReads(id)).toBe(2);
+        } finally {
+            if (app && typeof app.close === 'function') {
+                await app.close();
+            }
+        }
+    });
+
+    it('refuses a wrong access password with 403 without consuming a read', async () => {
+        const { buildServer } = await import('../../../server');
+        const app = await buildServer();
+        app.log.level = 'silent';
+
+        try {
+            const id = await createSecret(app, { maxReads: 2, accessPasswordHash });
+
+            const response = await app.inject({
+                method: 'GET',
+                url: `/api/v1/ots/${id}`,
+                headers: { 'x-access-password-hash': 'd3JvbmctaGFzaA' },
+            });
+
+            expect(response.statusCode).toBe(403);
+            expect(JSON.parse(response.body).ciphertext).toBeUndefined();
+            expect(await remainingReads(id)).toBe(2);
+        } finally {
+            if (app && typeof app.close === 'function') {
+                await app.close();
+            }
+        }
+    });
+
+    it('returns the secret for the right access password and consumes a read', async () => {
+        const { buildServer } = await import('../../../server');
+        const app = await buildServer();
+        app.log.level = 'silent';
+
+        try {
+            const id = await createSecret(app, { maxReads: 2, accessPasswordHash });
+            const headers = { 'x-access-password-hash': accessPasswordHash };
+
+            const first = await app.inject({ method: 'GET', url: `/api/v1/ots/${id}`, headers });
+            expect(first.statusCode).toBe(200);
+            expect(JSON.parse(first.body).ciphertext).toBe('c2VjcmV0');
+            expect(await remainingReads(id)).toBe(1);
+
+            const last = await app.inject({ method: 'GET', url: `/api/v1/ots/${id}`, headers });
+            expect(last.statusCode).toBe(200);
+            expect(await remainingReads(id)).toBeUndefined();
+
+            const gone = await app.inject({ method: 'GET', url: `/api/v1/ots/${id}`, headers });
+            expect(gone.statusCode).toBe(404);
+        } finally {
+            if (app && typeof app.close === 'function') {
+                await app.close();
+            }
+        }
+    });
+
+    it('returns a secret without an access password, ignoring any hash sent', async () => {
+        const { buildServer } = await import('../../../server');
+        const app = await buildServer();
+        app.log.level = 'silent';
+
+        try {
+            const id = await createSecret(app, { maxReads: 2 });
+
+            const plain = await app.inject({ method: 'GET', url: `/api/v1/ots/${id}` });
+            expect(plain.statusCode).toBe(200);
+            expect(JSON.parse(plain.body).ciphertext).toBe('c2VjcmV0');
+            expect(await remainingReads(id)).toBe(1);
+
+            const withHash = await app.inject({
+                method: 'GET',
+                url: `/api/v1/ots/${id}`,
+                headers: { 'x-access-password-hash': accessPasswordHash },
+            });
+            expect(withHash.statusCode).toBe(200);
+            expect(await remainingReads(id)).toBeUndefined();
+        } finally {
+            if (app && typeof app.close === 'function') {
+                await app.close();
+            }
+        }
+    });
+
+    it('refuses a format newer than X-Accept-Version with 422 without consuming a read', async () => {
+        const { buildServer } = await import('../../../server');
+        const app = await buildServer();
+        app.log.level = 'silent';
+
+        try {
+            const id = await createSecret(app, { kdf: 'argon2id', kdfParams: { version: 2 } });
+
+            const refused = await app.inject({
+                method: 'GET',
+                url: `/api/v1/ots/${id}`,
+                headers: { 'x-accept-version': '1' },
+            });
+            expect(refused.statusCode).toBe(422);
+            expect(JSON.parse(refused.body).error).toContain('ots redeem');
+            expect(await remainingReads(id)).toBe(1);
+
+            // Clients that do not say which formats they accept, such as the CLI, get the secret
+            const response = await app.inject({ method: 'GET', url: `/api/v1/ots/${id}` });
+            expect(response.statusCode).toBe(200);
+            expect(JSON.parse(response.body).kdfParams.version).toBe(2);
+        } finally {
+            if (app && typeof app.close === 'function') {
+                await app.close();
+            }
+        }
+    });
+
+    it('returns a legacy secret to a client accepting version 1', async () => {
+        const { buildServer } = await import('../../../server');
+        const app = await buildServer();
+        app.log.level = 'silent';
+
+        try {
+            const id = await createSecret(app, {});
+
+            const response = await app.inject({
+                method: 'GET',
+                url: `/api/v1/ots/${id}`,
+                headers: { 'x-accept-version': '1' },
+            });
+            expect(response.statusCode).toBe(200);
+            expect(await remainingReads(id)).toBeUndefined();
+        } finally {
+            if (app && typeof app.close === 'function') {
+                await app.close();
+            }
+        }
+    });
+});
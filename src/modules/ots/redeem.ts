@@ -1,4 +1,5 @@
 import type { BunSQLiteDatabase } from 'drizzle-orm/bun-sqlite';
+import { timingSafeEqual } from 'node:crypto';
 import { eq } from 'drizzle-orm';
 import { secrets } from '../../db/schema';
 import type * as schema from '../../db/schema';
@@ -11,6 +12,10 @@ import { findSecretById } from './repo';
  * - Verifies secret exists
  * - Checks expiration
  * - Validates remaining reads
+ * - Verifies the access password hash, if the secret has one
+ * - Checks the client can decrypt the secret's format, if it says which it can
+ * 
+ * A missing or wrong access password, or a format the client cannot decrypt, is refused without consuming a read.
  * 
  * If this is the last read (or burn-after-read), the secret is permanently deleted
  * BEFORE returning data to ensure it's removed from the database.
@@ -21,25 +26,37 @@ import { findSecretById } from './repo';
  * 
  * @param {BunSQLiteDatabase<typeof schema>} db - Database instance
  * @param {string} id - Server-generated secret identifier
- * @returns {object | { error: string }} Secret data if successful, or error object if failed
+ * @param {string} [accessPasswordHash] - Access password hash presented by the client
+ * @param {number} [acceptVersion] - Newest encryption format version the client can decrypt
+ * @returns {object | { error: string, status: number }} Secret data if successful, or error object if failed
  */
-export function redeemSecret(db: BunSQLiteDatabase<typeof schema>, id: string) {
+export function redeemSecret(db: BunSQLiteDatabase<typeof schema>, id: string, accessPasswordHash?: string, acceptVersion?: number) {
     // id is server-generated identifier - encryption key never sent to server
     // Encryption key only exists in URL query params, never accessed server-side
     const secret = findSecretById(db, id);
 
     if (!secret) {
-        return { error: 'Secret not found' };
+        return { error: 'Secret not found', status: 404 };
     }
 
     // Check expiration
     if (secret.expiresAt && secret.expiresAt < Date.now()) {
-        return { error: 'Secret expired' };
+        return { error: 'Secret expired', status: 404 };
     }
 
     // Check reads remaining
     if (secret.remainingReads <= 0) {
-        return { error: 'Secret already consumed' };
+        return { error: 'Secret already consumed', status: 404 };
+    }
+
+    // Check access password before consuming a read
+    if (secret.accessPasswordHash) {
+        if (!accessPasswordHash) {
+            return { error: 'Access password required', status: 401 };
+        }
+        if (!hashesEqual(secret.accessPasswordHash, accessPasswordHash)) {
+            return { error: 'Invalid access password', status: 403 };
+        }
     }
 
     // Parse kdfParams with fallback to defaults
@@ -63,6 +80,16 @@ export function redeemSecret(db: BunSQLiteDatabase<typeof schema>, id: string) {
         kdfParams.isPasswordProtected = false;
     }
 
+    // Secrets created by the CLI default to version 2 (AES-GCM), which the web page cannot decrypt
+    // Refuse them before consuming a read, so the secret is not destroyed for nothing
+    const version = typeof kdfParams.version === 'number' ? kdfParams.version : 1;
+    if (acceptVersion !== undefined && version > acceptVersion) {
+        return {
+            error: 'This secret was created with the ots CLI in a format this page cannot decrypt. It has not been opened; redeem it with: ots redeem <link>',
+            status: 422,
+        };
+    }
+
     // Store the secret data before deletion
     const secretData = {
         ciphertext: secret.ciphertext,
@@ -92,3 +119,15 @@ export function redeemSecret(db: BunSQLiteDatabase<typeof schema>, id: string) {
     return secretData;
 }
 
+/**
+ * Compares two access password hashes in constant time.
+ * 
+ * @param {string} stored - Hash stored with the secret
+ * @param {string} presented - Hash presented by the client
+ * @returns {boolean} True if the hashes match
+ */
+function hashesEqual(stored: string, presented: string): boolean {
+    const a = Buffer.from(stored);
+    const b = Buffer.from(presented);
+    return a.length === b.length && timingSafeEqual(a, b);
+}
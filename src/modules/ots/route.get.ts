@@ -38,6 +38,13 @@ export default async function registerOtsGetRoute(app: FastifyInstance) {
                     key: { type: 'string', description: 'Decryption key (never logged, stored, or accessed server-side)' },
                 },
             },
+            headers: {
+                type: 'object',
+                properties: {
+                    'x-access-password-hash': { type: 'string', description: 'Access password hash, required if the secret was created with one', maxLength: 512 },
+                    'x-accept-version': { type: 'string', description: 'Newest encryption format version the client can decrypt; newer secrets are refused without consuming a read', pattern: '^[0-9]+$' },
+                },
+            },
             response: {
                 200: {
                     description: 'Secret retrieved successfully',
@@ -50,6 +57,20 @@ export default async function registerOtsGetRoute(app: FastifyInstance) {
                         kdfParams: { type: 'object' },
                     },
                 },
+                401: {
+                    description: 'Access password required',
+                    type: 'object',
+                    properties: {
+                        error: { type: 'string' },
+                    },
+                },
+                403: {
+                    description: 'Invalid access password',
+                    type: 'object',
+                    properties: {
+                        error: { type: 'string' },
+                    },
+                },
                 404: {
                     description: 'Secret not found or expired',
                     type: 'object',
@@ -57,16 +78,27 @@ export default async function registerOtsGetRoute(app: FastifyInstance) {
                         error: { type: 'string' },
                     },
                 },
+                422: {
+                    description: 'Secret format newer than X-Accept-Version',
+                    type: 'object',
+                    properties: {
+                        error: { type: 'string' },
+                    },
+                },
             },
         },
     }, async (req, reply) => {
         const { id } = req.params as { id: string };
+        const accessPasswordHash = req.headers['x-access-password-hash'] as string | undefined;
+        const acceptVersionHeader = req.headers['x-accept-version'] as string | undefined;
+        const acceptVersion = acceptVersionHeader ? Number(acceptVersionHeader) : undefined;
         // Note: query.key is intentionally ignored - encryption keys are never accessed server-side
         // Server only uses its own generated ID to identify the secret
         const db = createDb();
-        const result = redeemSecret(db, id);
+        const result = redeemSecret(db, id, accessPasswordHash, acceptVersion);
         if ('error' in result) {
-            return reply.status(404).send(result);
+            const { status, ...body } = result;
+            return reply.status(status).send(body);
         }
         return reply.send(result);
     });